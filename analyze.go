@@ -0,0 +1,329 @@
+package httprouter
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// analyzedRoute is a route as Analyze compares it: its path parsed as the tree would place it, on its host.
+type analyzedRoute struct {
+	route      Route
+	host       string
+	segments   []templateSegment
+	predicates []string // the keys of its predicates, sorted
+	endpoint   *endpoint
+}
+
+func newAnalyzedRoutes(routes []Route) (analyzed []analyzedRoute) {
+	for _, route := range routes {
+		endpoint, err := newRouteEndpoint(route, statusHandler(http.StatusOK)) // the handler plays no part
+		if err != nil {
+			continue
+		}
+
+		var host string
+		if len(route.Host) > 0 {
+			pattern, err := parseHostPattern(route.Host)
+			if err != nil {
+				continue
+			}
+			host = pattern.source
+		}
+
+		var predicates []string
+		if len(endpoint.predicateKey) > 0 {
+			predicates = strings.Split(endpoint.predicateKey, "\n")
+		}
+		analyzed = append(analyzed, analyzedRoute{route: route, host: host, segments: endpoint.template.segments,
+			predicates: predicates, endpoint: endpoint})
+	}
+	return analyzed
+}
+
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+
+// analyzePrecedence compares every pair of routes that some path matches both of. Where they are different patterns,
+// the one the tree tries first takes every method it serves, and the other is reached only by backtracking for the
+// methods it doesn't; where they are the same pattern, the candidates of each method are tried in registration order.
+func analyzePrecedence(routes []analyzedRoute) (findings []Finding) {
+	for later := range routes {
+		for earlier := 0; earlier < later; earlier++ {
+			first, second := routes[earlier], routes[later]
+			if first.host != second.host {
+				continue
+			}
+
+			order, overlap := compareTemplates(first.segments, second.segments)
+			if !overlap {
+				continue
+			} else if order == 0 {
+				if finding, found := analyzeCandidates(first, second); found {
+					findings = append(findings, finding)
+				}
+				continue
+			} else if order > 0 {
+				first, second = second, first
+			}
+
+			methods, backtracked := first.route.AllowedMethods, second.route.AllowedMethods
+			if shadowed := coveredMethods(backtracked, methods); shadowed != 0 && covers(first.segments, second.segments) {
+				findings = append(findings, Finding{Kind: FindingUnreachable, Route: second.route,
+					Related: []Route{first.route}, Methods: shadowed})
+			}
+			if dependent := uncoveredMethods(backtracked, methods); dependent != 0 {
+				findings = append(findings, Finding{Kind: FindingMethodDependent, Route: second.route,
+					Related: []Route{first.route}, Methods: dependent})
+			}
+		}
+	}
+	return findings
+}
+
+// analyzeCandidates compares two routes with the same pattern, the second registered after the first. For each
+// method they share, the first is selected whenever its predicates hold, so the second is never reached if it
+// requires all of the first's predicates and more, or the same ones, unless their versions tell them apart: the
+// version asked for chooses among every versioned candidate whose predicates hold (see endpoint.selectVersion).
+func analyzeCandidates(first, second analyzedRoute) (Finding, bool) {
+	shared := first.route.AllowedMethods & second.route.AllowedMethods
+	if shared == 0 {
+		return Finding{}, false
+	}
+
+	if (isStrictSubset(first.predicates, second.predicates) && !versionsApart(first.endpoint, second.endpoint)) ||
+		(strings.Join(first.predicates, "\n") == strings.Join(second.predicates, "\n") && !first.endpoint.admits(second.endpoint)) {
+		return Finding{Kind: FindingUnreachable, Route: second.route, Related: []Route{first.route}, Methods: shared}, true
+	}
+	return Finding{}, false
+}
+
+// versionsApart reports whether the version asked for can select either endpoint over the other, whatever their
+// predicates: only if both serve a version, and not the same one. (admits asks the same of endpoints whose predicates
+// are the same, which it alone can tell.)
+func versionsApart(first, second *endpoint) bool {
+	return first.versioned() && second.versioned() && compareVersions(first.version, second.version) != 0
+}
+
+// analyzeVariableNames finds routes whose variables share a position in the tree with those of an earlier route
+// but spell their names differently.
+func analyzeVariableNames(routes []analyzedRoute) (findings []Finding) {
+	type position struct {
+		names string
+		route int
+	}
+	positions := map[string]position{}
+
+	for index, route := range routes {
+		var key strings.Builder
+		key.WriteString(route.host)
+		for _, segment := range route.segments {
+			if segment.kind == segmentWildcard {
+				break
+			} else if segment.kind == segmentStatic {
+				key.WriteString("/" + segment.text)
+				continue
+			}
+
+			names := segment.name
+			if segment.matcher != nil {
+				key.WriteString("/" + segment.matcher.key)
+				names = variableNames(segment.matcher)
+			} else {
+				key.WriteString("/:")
+			}
+
+			if existing, found := positions[key.String()]; !found {
+				positions[key.String()] = position{names: names, route: index}
+			} else if existing.names != names {
+				findings = append(findings, Finding{Kind: FindingVariableNames, Route: route.route,
+					Related: []Route{routes[existing.route].route}, Methods: route.route.AllowedMethods})
+				break
+			}
+		}
+	}
+	return findings
+}
+
+// analyzeWildcards finds wildcard routes whose parent node leads to other routes: any path beneath those routes'
+// prefixes that none of them matches falls through to the wildcard.
+func analyzeWildcards(routes []analyzedRoute) (findings []Finding) {
+	for index, wildcard := range routes {
+		position := len(wildcard.segments) - 1
+		if position < 0 || wildcard.segments[position].kind != segmentWildcard {
+			continue
+		}
+
+		var siblings []Route
+		for other, sibling := range routes {
+			if other != index && sibling.host == wildcard.host && len(sibling.segments) > position &&
+				sibling.segments[position].kind != segmentWildcard && sharesPrefix(wildcard.segments, sibling.segments, position) {
+				siblings = append(siblings, sibling.route)
+			}
+		}
+		if len(siblings) > 0 {
+			findings = append(findings, Finding{Kind: FindingWildcardSwallows, Route: wildcard.route, Related: siblings,
+				Methods: wildcard.route.AllowedMethods})
+		}
+	}
+	return findings
+}
+
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+
+// compareTemplates reports whether some path matches both a and b and, if so, which the tree tries first: a negative
+// order for a, a positive one for b, and zero for the same pattern. Where neither outranks the other (variables with
+// different constraints but the same amount of literal text), the tree tries them in registration order, so a is
+// taken to be the one registered first.
+func compareTemplates(a, b []templateSegment) (order int, overlap bool) {
+	for index := 0; ; index++ {
+		if index == len(a) || index == len(b) {
+			return order, len(a) == len(b)
+		}
+
+		left, right := a[index], b[index]
+		if left.kind == segmentWildcard || right.kind == segmentWildcard {
+			if order == 0 && left.kind != right.kind {
+				order = segmentOrder(left, right)
+			}
+			return order, true
+		}
+
+		same, overlap := compareSegments(left, right)
+		if !overlap {
+			return 0, false
+		} else if order == 0 && !same {
+			order = segmentOrder(left, right)
+		}
+	}
+}
+
+// compareSegments reports whether left and right are the same node of the tree, and whether some segment matches
+// both. Two variables with different constraints are taken to overlap only if one accepts every segment.
+func compareSegments(left, right templateSegment) (same, overlap bool) {
+	switch {
+	case left.kind == segmentStatic && right.kind == segmentStatic:
+		return left.text == right.text, left.text == right.text
+	case left.kind == segmentStatic:
+		return false, acceptsSegment(right, left.text)
+	case right.kind == segmentStatic:
+		return false, acceptsSegment(left, right.text)
+	case left.matcher.equals(right.matcher):
+		return true, true
+	default:
+		return false, acceptsAnySegment(left) || acceptsAnySegment(right)
+	}
+}
+
+// segmentOrder returns which of two different segments (at the same position) the tree tries first, as treeNode
+// orders its children: static text, then variables by how much literal text they have, then the wildcard.
+func segmentOrder(left, right templateSegment) int {
+	if left.kind != right.kind {
+		if left.kind < right.kind {
+			return -1
+		}
+		return 1
+	} else if right.matcher.outranks(left.matcher) {
+		return 1
+	}
+	return -1
+}
+
+// covers reports whether every path that b matches is also matched by a.
+func covers(a, b []templateSegment) bool {
+	for index, left := range a {
+		if left.kind == segmentWildcard {
+			return index < len(b)
+		} else if index == len(b) || b[index].kind == segmentWildcard {
+			return false
+		}
+
+		right := b[index]
+		switch {
+		case left.kind == segmentStatic:
+			if right.kind != segmentStatic || right.text != left.text {
+				return false
+			}
+		case right.kind == segmentStatic:
+			if !acceptsSegment(left, right.text) {
+				return false
+			}
+		case !acceptsAnySegment(left) && !left.matcher.equals(right.matcher):
+			return false
+		}
+	}
+	return len(a) == len(b)
+}
+
+// sharesPrefix reports whether the first count segments of a and b lead to the same node.
+func sharesPrefix(a, b []templateSegment, count int) bool {
+	for index := 0; index < count; index++ {
+		if same, _ := compareSegments(a[index], b[index]); !same || a[index].kind == segmentWildcard {
+			return false
+		}
+	}
+	return true
+}
+
+func acceptsSegment(segment templateSegment, text string) bool {
+	if segment.matcher == nil {
+		return len(text) > 0
+	}
+	return segment.matcher.matches(text, false)
+}
+
+// acceptsAnySegment reports whether a variable segment accepts every non-empty segment, as a plain ":name" does.
+func acceptsAnySegment(segment templateSegment) bool {
+	if segment.matcher == nil {
+		return true
+	} else if parts := segment.matcher.parts; len(parts) != 1 || parts[0].constraint == nil {
+		return false
+	} else {
+		return acceptAllConstraints[parts[0].constraint.source]
+	}
+}
+
+var acceptAllConstraints = map[string]bool{".*": true, ".+": true, "[^/]*": true, "[^/]+": true}
+
+func variableNames(matcher *segmentMatcher) string {
+	var names []string
+	for _, part := range matcher.parts {
+		if part.variable {
+			names = append(names, part.name)
+		}
+	}
+	return strings.Join(names, ",")
+}
+
+// coveredMethods returns those of methods that by also serves, MethodAny in methods standing for every method it
+// doesn't name.
+func coveredMethods(methods, by Method) Method {
+	if by&MethodAny != 0 {
+		return methods
+	}
+	covered := methods & by
+	if methods&MethodAny != 0 {
+		covered |= by
+	}
+	return covered &^ MethodAny
+}
+
+// uncoveredMethods returns those of methods that by doesn't serve, MethodAny standing for those that neither names.
+func uncoveredMethods(methods, by Method) Method {
+	if by&MethodAny != 0 {
+		return 0
+	}
+	return methods &^ by
+}
+
+// isStrictSubset reports whether the sorted keys of subset are all among the sorted keys of set, which has more.
+func isStrictSubset(subset, set []string) bool {
+	if len(subset) >= len(set) {
+		return false
+	}
+	for _, key := range subset {
+		if index := sort.SearchStrings(set, key); index == len(set) || set[index] != key {
+			return false
+		}
+	}
+	return true
+}
@@ -263,3 +263,20 @@ func BenchmarkRouterLongStatic(b *testing.B) {
 		router.ServeHTTP(nil, request)
 	}
 }
+
+// The check every request pays for before it is routed, which must let a plain path through without the table walk
+// of normalizeEncoding: a search for '%' and a word-at-a-time scan for non-ASCII bytes, and no copy.
+func BenchmarkNormalizeEncodingStatic(b *testing.B) {
+	path := buildLongStaticPath(20)
+	if mayNeedEncoding(path) {
+		b.Fatal("a plain ASCII path must take the fast path")
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if mayNeedEncoding(path) {
+			_, _ = normalizeEncoding(path)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package httprouter
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// Routes registered for the same method and path with different versions or predicates share one endpoint chain,
+// linked through next in registration order. The first endpoint in the chain is the one the tree resolves; the router
+// then selects the candidate that serves the request.
+
+// newCandidateKey identifies a set of predicates regardless of the order they are listed in. It reports false if any
+// predicate is unusable.
+func newCandidateKey(predicates []Predicate) (string, bool) {
+	if len(predicates) == 0 {
+		return "", true
+	}
+
+	keys := make([]string, 0, len(predicates))
+	for _, predicate := range predicates {
+		if predicate.match == nil {
+			return "", false
+		}
+		keys = append(keys, predicate.key)
+	}
+	sort.Strings(keys)
+	return strings.Join(keys, "\n"), true
+}
+
+// admits reports whether other may join this endpoint's chain: it must differ from every candidate in its predicates
+// or in its version, and a route without a version serves every version.
+func (this *endpoint) admits(other *endpoint) bool {
+	for candidate := this; candidate != nil; candidate = candidate.next {
+		if candidate.predicateKey != other.predicateKey {
+			continue
+		} else if len(candidate.version) == 0 || len(other.version) == 0 || compareVersions(candidate.version, other.version) == 0 {
+			return false
+		}
+	}
+	return true
+}
+func (this *endpoint) chain(other *endpoint) {
+	tail := this
+	for tail.next != nil {
+		tail = tail.next
+	}
+	tail.next = other
+}
+
+// conditional reports whether serving this endpoint depends on more than the method and path.
+func (this *endpoint) conditional() bool {
+	return this.next != nil || len(this.version) > 0 || len(this.predicates) > 0
+}
+
+func (this *endpoint) versioned() bool {
+	return len(this.version) > 0
+}
+
+// selectPredicates returns the first candidate whose predicates all hold for the request, or nil if there is none.
+func (this *endpoint) selectPredicates(request *http.Request) *endpoint {
+	for candidate := this; candidate != nil; candidate = candidate.next {
+		if candidate.matches(request) {
+			return candidate
+		}
+	}
+	return nil
+}
+func (this *endpoint) matches(request *http.Request) bool {
+	for _, predicate := range this.predicates {
+		if !predicate.match(request) {
+			return false
+		}
+	}
+	return true
+}
+
+// selectVersion returns the versioned endpoint, among this one and those after it whose predicates hold for the
+// request, serving the requested version, or nil if there is none. Candidates with different predicates compete on
+// their versions alike, so the version asked for decides between them. An empty request falls back to the default
+// version, and to the latest version if none of them has the default.
+func (this *endpoint) selectVersion(request *http.Request, requested, defaultVersion string) *endpoint {
+	if len(requested) == 0 {
+		if selected := this.selectVersion(request, defaultVersion, VersionLatest); selected != nil {
+			return selected
+		}
+		requested = VersionLatest
+	}
+
+	var selected *endpoint
+	for candidate := this; candidate != nil; candidate = candidate.next {
+		if !candidate.versioned() || !candidate.matches(request) {
+			continue
+		} else if requested == VersionLatest {
+			if selected == nil || compareVersions(candidate.version, selected.version) > 0 {
+				selected = candidate
+			}
+		} else if compareVersions(candidate.version, requested) == 0 {
+			return candidate
+		}
+	}
+	return selected
+}
@@ -0,0 +1,125 @@
+package httprouter
+
+import (
+	"regexp"
+	"regexp/syntax"
+	"strings"
+)
+
+// constraint restricts the segments a variable accepts, written after its name in braces: either one of the named
+// constraints below (e.g. ":id{int}") or a regular expression that must match the whole segment (e.g.
+// ":slug{[a-z-]+}"). Variables that share a position and the same constraint share a node in the tree; the source
+// text is what identifies them.
+type constraint struct {
+	source string
+	match  func(string) bool
+}
+
+func parseConstraint(source string) (*constraint, error) {
+	if match, found := namedConstraints[source]; found {
+		return &constraint{source: source, match: match}, nil
+	}
+
+	if len(source) == 0 {
+		return nil, ErrInvalidConstraint
+	} else if _, err := syntax.Parse(source, syntax.Perl); err != nil {
+		return nil, ErrInvalidConstraint // e.g. "a)|(b", which would close the group below early and escape its anchors
+	}
+	expression, err := regexp.Compile("^(?:" + source + ")$")
+	if err != nil {
+		return nil, ErrInvalidConstraint
+	}
+	return &constraint{source: source, match: expression.MatchString}, nil
+}
+
+// cutConstraint splits "{constraint}rest" at the brace that closes the leading one, allowing the braces of regular
+// expression repetition (e.g. "{[a-z]{2,3}}") to nest inside. It reports false if the braces never balance.
+func cutConstraint(value string) (source, rest string, ok bool) {
+	depth := 0
+	for index := 0; index < len(value); index++ {
+		switch value[index] {
+		case '{':
+			depth++
+		case '}':
+			if depth--; depth == 0 {
+				return value[1:index], value[index+1:], true
+			}
+		}
+	}
+	return "", "", false
+}
+
+// splitOutsideBraces splits value at each separator that does not fall inside a constraint, so the alternation in an
+// expression such as "/:kind{a|b}" survives ParseRoutes splitting its paths on "|".
+func splitOutsideBraces(value, separator string) (items []string) {
+	depth, start := 0, 0
+	for index := 0; index < len(value); index++ {
+		switch {
+		case value[index] == '{':
+			depth++
+		case value[index] == '}' && depth > 0:
+			depth--
+		case depth == 0 && strings.HasPrefix(value[index:], separator):
+			items = append(items, value[start:index])
+			start = index + len(separator)
+		}
+	}
+	return append(items, value[start:])
+}
+
+var namedConstraints = map[string]func(string) bool{
+	"int":   isInteger,
+	"uuid":  isUUID,
+	"alpha": isAlpha,
+	"alnum": isAlphanumeric,
+}
+
+func isInteger(value string) bool {
+	value = strings.TrimPrefix(value, "-")
+	if len(value) == 0 {
+		return false
+	}
+	for index := 0; index < len(value); index++ {
+		if value[index] < '0' || value[index] > '9' {
+			return false
+		}
+	}
+	return true
+}
+func isUUID(value string) bool {
+	if len(value) != 36 {
+		return false
+	}
+	for index := 0; index < len(value); index++ {
+		if index == 8 || index == 13 || index == 18 || index == 23 {
+			if value[index] != '-' {
+				return false
+			}
+		} else if !isHex(value[index]) {
+			return false
+		}
+	}
+	return true
+}
+func isAlpha(value string) bool {
+	for index := 0; index < len(value); index++ {
+		if !isLetter(value[index]) {
+			return false
+		}
+	}
+	return len(value) > 0
+}
+func isAlphanumeric(value string) bool {
+	for index := 0; index < len(value); index++ {
+		if !isLetter(value[index]) && (value[index] < '0' || value[index] > '9') {
+			return false
+		}
+	}
+	return len(value) > 0
+}
+func isLetter(value byte) bool {
+	return (value >= 'a' && value <= 'z') || (value >= 'A' && value <= 'Z')
+}
+func isHex(value byte) bool {
+	return (value >= '0' && value <= '9') || (value >= 'a' && value <= 'f') || (value >= 'A' && value <= 'F')
+}
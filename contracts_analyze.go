@@ -0,0 +1,71 @@
+package httprouter
+
+import "strings"
+
+// Finding is something Analyze noticed about how a route interacts with the others in its table.
+type Finding struct {
+	Kind    FindingKind
+	Route   Route   // the route the finding is about
+	Related []Route // the routes responsible, in the order they were given
+	Methods Method  // the methods of Route affected, MethodAny standing for every method the route serves through it
+}
+
+// FindingKind classifies a Finding.
+type FindingKind uint8
+
+const (
+	// FindingUnreachable is a route that can never be reached with the methods given, as a route tried before it
+	// matches every path it does (or is registered for the same path without a version or predicates to tell the two
+	// apart) and serves those methods.
+	FindingUnreachable FindingKind = iota
+
+	// FindingMethodDependent is a route whose pattern overlaps that of a route tried before it, so that for the paths
+	// both match, which of them serves the request depends on its method: the other serves its own methods, and the
+	// router backtracks to this one for the methods given.
+	FindingMethodDependent
+
+	// FindingVariableNames is a route naming a variable differently from a related route with a variable at the same
+	// position, which routes both through the same node; each still reads its values under its own names.
+	FindingVariableNames
+
+	// FindingWildcardSwallows is a wildcard route that also serves, with the methods given, paths beneath the prefixes
+	// of its sibling routes that none of them matches, instead of those requests being answered as not found.
+	FindingWildcardSwallows
+)
+
+func (this FindingKind) String() string {
+	switch this {
+	case FindingUnreachable:
+		return "unreachable"
+	case FindingMethodDependent:
+		return "method-dependent"
+	case FindingVariableNames:
+		return "variable-names"
+	case FindingWildcardSwallows:
+		return "wildcard-swallows"
+	default:
+		return "unknown"
+	}
+}
+func (this Finding) String() string {
+	related := make([]string, 0, len(this.Related))
+	for _, route := range this.Related {
+		related = append(related, route.String())
+	}
+	return this.Kind.String() + ": " + this.Route.String() + " (" + this.Methods.String() + ") with " +
+		strings.Join(related, ", ")
+}
+
+// Analyze reports how the routes given would interact once registered together: routes that can never be reached
+// with some method, patterns that overlap such that the route serving a path depends on the method, variables at
+// the same position under different names, and wildcards that catch what their sibling routes don't match. Routes
+// on different hosts never interact. Routes that New would reject outright are skipped, and a variable constraint
+// is taken to accept every segment only if it is written ".*", ".+", "[^/]*" or "[^/]+", so routes whose
+// constraints differ otherwise are not compared.
+func Analyze(routes []Route) (findings []Finding) {
+	analyzed := newAnalyzedRoutes(routes)
+	findings = append(findings, analyzePrecedence(analyzed)...)
+	findings = append(findings, analyzeVariableNames(analyzed)...)
+	findings = append(findings, analyzeWildcards(analyzed)...)
+	return findings
+}
@@ -1,35 +1,136 @@
 package httprouter
 
-import "net/http"
+import (
+	"errors"
+	"net/http"
+)
 
-func RequireNew(options ...Option) http.Handler {
+func RequireNew(options ...Option) Router {
 	if handler, err := New(options...); err != nil {
 		panic(err)
 	} else {
 		return handler
 	}
 }
-func New(options ...Option) (http.Handler, error) {
+func New(options ...Option) (Router, error) {
+	if router, err := build(options); err != nil {
+		return nil, err
+	} else {
+		return router, nil
+	}
+}
+func build(options []Option) (*builtRouter, error) {
 	var config configuration
 	Options.With(Options.defaults(options)...)(&config)
 
-	treeRoot := &treeNode{}
+	for _, mount := range config.Mounts {
+		config.Routes = append(config.Routes, mount.routes(config.NotFound, config.MethodNotAllowed)...)
+	}
+
+	// Each host pattern gets a tree of its own; routes without a Host share the default tree.
+	trees := []routeTree{{root: &treeNode{}}}
+	names := namedRoutes{}
+	var rejected []error
 	for _, route := range config.Routes {
-		if err := treeRoot.Add(route); err != nil {
+		var index int
+		var err error
+		if trees, index, err = treeFor(trees, route); err == nil {
+			if err = trees[index].root.add(route, config.handler(route)); err == nil {
+				trees[index] = trees[index].withGroups(route.group, 1)
+				err = names.Add(route)
+			}
+		}
+
+		if err == nil {
+			continue
+		} else if !config.JoinErrors {
 			return nil, err
 		}
+		rejected = append(rejected, err)
+	}
+	if len(rejected) > 0 {
+		return nil, errors.Join(rejected...)
 	}
-	// Routes are immutable after registration, so collapse single-child static chains into multi-segment
-	// nodes once here. This finalizes the tree, letting Resolve settle a non-branching path in one comparison
-	// instead of one recursive frame per segment.
-	treeRoot.compact()
 
-	router := newRouter(treeRoot, config.NotFound, config.MethodNotAllowed, config.Monitor)
-	if config.Recovery == nil {
-		return router, nil
+	for _, tree := range trees {
+		tree.root.finalize(config.ImplicitHead)
+	}
+	return assemble(config, trees, names), nil
+}
+
+// assemble builds the router for config around trees that are ready for routing, the first of which serves any
+// host that none of the others (one per host pattern) serves.
+func assemble(config configuration, trees []routeTree, names namedRoutes) *builtRouter {
+	var serverMethods Method
+	var hosts *hostTable
+	var groups map[routeResolver][]*groupScope
+	for _, tree := range trees {
+		serverMethods |= tree.root.methods()
+		if len(tree.groups) > 0 {
+			if groups == nil {
+				groups = map[routeResolver][]*groupScope{}
+			}
+			groups[tree.root] = tree.scopes()
+		}
+		if tree.pattern == nil {
+			continue
+		} else if hosts == nil {
+			hosts = newHostTable()
+		}
+		hosts.Add(tree.pattern, tree.root)
+	}
+
+	router := newRouter(trees[0].root, config.NotFound, config.MethodNotAllowed, config.Monitor)
+	router.hosts = hosts
+	router.groups = groups
+	router.trailingSlash = config.TrailingSlash
+	router.normalizer = config.Normalization
+	router.caseMatching = config.CaseMatching
+	router.encodedSlash = config.EncodedSlash
+	router.badRequest = config.BadRequest
+	router.notImplemented = config.NotImplemented
+	router.automaticOptions = config.AutomaticOptions
+	router.serverMethods = serverMethods
+	router.versioning = config.Versioning
+	router.defaultVersion = config.DefaultVersion
+	router.notAcceptable = config.NotAcceptable
+	router.predicateMismatch = config.PredicateMismatch
+
+	// Pre-routing middleware sees every request before it is resolved; recovery, if any, encloses all of it.
+	handler := wrap(router, config.PreRoutingMiddleware)
+	if config.Recovery != nil {
+		handler = newRecoveryRouter(handler, config.Recovery, config.Monitor)
+	}
+
+	return &builtRouter{Handler: handler, config: config, trees: trees, names: names}
+}
+
+// treeFor returns the index in trees of the tree for the host of route, adding one to trees if it is the first route
+// with that host.
+func treeFor(trees []routeTree, route Route) ([]routeTree, int, error) {
+	if len(route.Host) == 0 {
+		return trees, 0, nil
+	}
+
+	pattern, err := parseHostPattern(route.Host)
+	if err != nil {
+		return trees, 0, newRouteError(route, err)
+	}
+	for index, tree := range trees {
+		if tree.pattern != nil && tree.pattern.source == pattern.source {
+			return trees, index, nil
+		}
 	}
+	return append(trees, routeTree{pattern: pattern, root: &treeNode{}}), len(trees), nil
+}
 
-	return newRecoveryRouter(router, config.Recovery, config.Monitor), nil
+// handler returns the handler that serves route: its own, wrapped by its middleware and then by the router's.
+func (this configuration) handler(route Route) http.Handler {
+	handler := wrap(wrap(route.Handler, route.Middleware), this.Middleware)
+	if this.trackRequests && handler != nil {
+		handler = &trackedHandler{Handler: handler}
+	}
+	return handler
 }
 
 func (singleton) With(options ...Option) Option {
@@ -47,36 +148,127 @@ func (singleton) AddRoute(method, path string, handler http.Handler) Option {
 func (singleton) Routes(value ...Route) Option {
 	return func(this *configuration) { this.Routes = append(this.Routes, value...) } // can be empty
 }
+
+// Mount serves every request at or beneath prefix (e.g. "/admin/") with handler, which may be another router, an
+// http.FileServer or any other http.Handler. The handler sees the path relative to the mount point, in URL.Path,
+// URL.RawPath and RequestURI alike, and MountPrefixFromContext reports the prefix that was stripped. A router mounted
+// this way answers requests it has no route for with this router's NotFound and MethodNotAllowed handlers, unless it
+// was given its own.
+func (singleton) Mount(prefix string, handler http.Handler) Option {
+	return func(this *configuration) { this.Mounts = append(this.Mounts, mount{prefix: prefix, handler: handler}) }
+}
+
+// JoinErrors has New (and ReloadableRouter) register every route it can and then report all of those it rejects, each
+// a *RouteError, as one error (see errors.Join), instead of stopping at the first.
+func (singleton) JoinErrors(value bool) Option {
+	return func(this *configuration) { this.JoinErrors = value }
+}
 func (singleton) MethodNotAllowed(value http.Handler) Option {
 	return func(this *configuration) { this.MethodNotAllowed = value } // must not be nil
 }
 func (singleton) NotFound(value http.Handler) Option {
 	return func(this *configuration) { this.NotFound = value } // must not be nil
 }
+func (singleton) NotImplemented(value http.Handler) Option {
+	return func(this *configuration) { this.NotImplemented = value } // can be nil which means to answer as if the method were known
+}
+func (singleton) AutomaticOptions(value bool) Option {
+	return func(this *configuration) { this.AutomaticOptions = value }
+}
+func (singleton) ImplicitHead(value bool) Option {
+	return func(this *configuration) { this.ImplicitHead = value }
+}
+func (singleton) Versioning(value VersionSelector) Option {
+	return func(this *configuration) { this.Versioning = value }
+}
+func (singleton) DefaultVersion(value string) Option {
+	return func(this *configuration) { this.DefaultVersion = value } // VersionLatest (the default) always serves the latest
+}
+func (singleton) NotAcceptable(value http.Handler) Option {
+	return func(this *configuration) { this.NotAcceptable = value } // must not be nil
+}
+func (singleton) PredicateMismatch(value http.Handler) Option {
+	return func(this *configuration) { this.PredicateMismatch = value } // can be nil which means to answer as not found
+}
+func (singleton) BadRequest(value http.Handler) Option {
+	return func(this *configuration) { this.BadRequest = value } // must not be nil
+}
 func (singleton) Recovery(value RecoveryFunc) Option {
 	return func(this *configuration) { this.Recovery = value } // can be nil which means to not handle a panic
 }
+
+// Middleware wraps the handler of every route once it has been resolved, so it sees the request as routed: its
+// Pattern, its values and RouteFromContext. It runs ahead of any group or route middleware, the first listed
+// outermost, and only for requests that match a route; later calls append to earlier ones.
+func (singleton) Middleware(value ...func(http.Handler) http.Handler) Option {
+	return func(this *configuration) { this.Middleware = append(this.Middleware, value...) }
+}
+
+// PreRoutingMiddleware wraps the router itself, so it sees every request (including those no route matches) before
+// it is resolved, and may rewrite it. It runs inside Recovery, so a panic it raises is recovered too.
+func (singleton) PreRoutingMiddleware(value ...func(http.Handler) http.Handler) Option {
+	return func(this *configuration) { this.PreRoutingMiddleware = append(this.PreRoutingMiddleware, value...) }
+}
 func (singleton) Monitor(value Monitor) Option {
 	return func(this *configuration) { this.Monitor = value }
 }
+func (singleton) TrailingSlash(value TrailingSlashPolicy) Option {
+	return func(this *configuration) { this.TrailingSlash = value }
+}
+func (singleton) CaseMatching(value CaseMatchingPolicy) Option {
+	return func(this *configuration) { this.CaseMatching = value }
+}
+func (singleton) EncodedSlashes(value EncodedSlashPolicy) Option {
+	return func(this *configuration) { this.EncodedSlash = value }
+}
+func (singleton) NormalizePath(irregularities PathIrregularity, policy NormalizationPolicy) Option {
+	return func(this *configuration) { this.Normalization.set(irregularities, policy) } // later calls override earlier ones
+}
 
 func (singleton) defaults(options []Option) []Option {
 	return append([]Option{
 		Options.NotFound(statusHandler(http.StatusNotFound)),
 		Options.MethodNotAllowed(statusHandler(http.StatusMethodNotAllowed)),
+		Options.BadRequest(statusHandler(http.StatusBadRequest)),
+		Options.NotImplemented(statusHandler(http.StatusNotImplemented)),
+		Options.AutomaticOptions(false),
+		Options.ImplicitHead(false),
+		Options.DefaultVersion(VersionLatest),
+		Options.NotAcceptable(statusHandler(http.StatusNotAcceptable)),
+		Options.JoinErrors(false),
 		Options.Recovery(nil), // by default, don't handle a panic
 		Options.Monitor(&nop{}),
+		Options.TrailingSlash(TrailingSlashStrict),
+		Options.CaseMatching(CaseSensitive),
+		Options.EncodedSlashes(EncodedSlashPreserve),
 	}, options...)
 }
 
 ////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
 
 type configuration struct {
-	Routes           []Route
-	NotFound         http.Handler
-	MethodNotAllowed http.Handler
-	Recovery         RecoveryFunc
-	Monitor          Monitor
+	Routes               []Route
+	Mounts               []mount
+	JoinErrors           bool
+	NotFound             http.Handler
+	MethodNotAllowed     http.Handler
+	BadRequest           http.Handler
+	NotImplemented       http.Handler
+	AutomaticOptions     bool
+	ImplicitHead         bool
+	Versioning           VersionSelector
+	DefaultVersion       string
+	NotAcceptable        http.Handler
+	PredicateMismatch    http.Handler
+	Recovery             RecoveryFunc
+	Middleware           []func(http.Handler) http.Handler
+	PreRoutingMiddleware []func(http.Handler) http.Handler
+	Monitor              Monitor
+	TrailingSlash        TrailingSlashPolicy
+	CaseMatching         CaseMatchingPolicy
+	EncodedSlash         EncodedSlashPolicy
+	Normalization        pathNormalizer
+	trackRequests        bool // counts the requests in flight for each route, which ReloadableRouter can wait for
 }
 type Option func(*configuration)
 type singleton struct{}
@@ -1,12 +1,57 @@
 package httprouter
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+)
 
 var (
-	ErrUnknownMethod     = errors.New("the method specified is not understood")
-	ErrNilHandler        = errors.New("the handler specified for this route must not be nil")
-	ErrRouteExists       = errors.New("the method and path specified for this route already exists")
-	ErrMalformedPath     = errors.New("the path specified for this route is malformed")
-	ErrInvalidCharacters = errors.New("the path specified for this route contains invalid characters")
-	ErrInvalidWildcard   = errors.New("the wildcard path specified must only contain a single asterisk '*'")
+	ErrUnknownMethod      = errors.New("the method specified is not understood")
+	ErrNilHandler         = errors.New("the handler specified for this route must not be nil")
+	ErrRouteExists        = errors.New("the method and path specified for this route already exists")
+	ErrMalformedPath      = errors.New("the path specified for this route is malformed")
+	ErrInvalidCharacters  = errors.New("the path specified for this route contains invalid characters")
+	ErrInvalidWildcard    = errors.New("the wildcard path specified must only contain a single asterisk '*'")
+	ErrInvalidConstraint  = errors.New("the constraint specified for a path variable is malformed or not understood")
+	ErrMalformedHost      = errors.New("the host specified for this route is malformed")
+	ErrInvalidPredicate   = errors.New("the predicate specified for this route is malformed")
+	ErrInvalidMethod      = errors.New("the method to register must be an uppercase token other than ANY")
+	ErrTooManyMethods     = errors.New("no more methods can be registered")
+	ErrDuplicateRouteName = errors.New("the name specified for this route already names a route with another path")
+	ErrUnknownRouteName   = errors.New("no route has the name specified")
+	ErrMissingParam       = errors.New("a value must be given for every variable and wildcard of the route")
+	ErrExtraParam         = errors.New("a value was given for a parameter the route does not have")
+	ErrDuplicateParam     = errors.New("more than one value was given for the same parameter")
+	ErrInvalidParam       = errors.New("the value given for a parameter would not route back to the same value")
+	ErrUnknownFormat      = errors.New("the export format specified is not understood")
+	ErrRouteNotFound      = errors.New("the route specified is not one the router serves")
 )
+
+// RouteError is the error New (and ReloadableRouter) reports for a route it rejects. It wraps one of the errors above,
+// which errors.Is still finds, and locates the problem: Fragment is the '/'-delimited part of the route's Path (or,
+// for ErrMalformedHost, the '.'-delimited label of its Host) at fault, beginning Offset bytes in. Where the problem
+// is with the route as a whole (its methods, its handler or a route it duplicates), Fragment is empty and Offset is -1.
+type RouteError struct {
+	Route    Route
+	Fragment string
+	Offset   int
+	Err      error
+}
+
+func (this *RouteError) Error() string {
+	if this.Offset < 0 {
+		return this.Route.String() + ": " + this.Err.Error()
+	}
+	return fmt.Sprintf("%s: %s (%q at offset %d)", this.Route, this.Err, this.Fragment, this.Offset)
+}
+func (this *RouteError) Unwrap() error { return this.Err }
+
+// newRouteError returns err, which route was rejected with, as a *RouteError for route: err itself if the parser
+// already located the problem, or err wrapped without a location.
+func newRouteError(route Route, err error) *RouteError {
+	if located, ok := err.(*RouteError); ok {
+		located.Route = route
+		return located
+	}
+	return &RouteError{Route: route, Offset: -1, Err: err}
+}
@@ -0,0 +1,34 @@
+package httprouter
+
+import "io"
+
+// ExportFormat selects how Export renders a router's routes.
+type ExportFormat uint8
+
+const (
+	// ExportText is a table of the routes, one per line, with the methods, pattern and handler type of each.
+	ExportText ExportFormat = iota
+
+	// ExportJSON is a JSON document holding both the routes and the nodes of the routing tree, for tooling.
+	ExportJSON
+
+	// ExportDOT is a Graphviz graph of the routing tree as compacted, one cluster per host pattern. Static edges are
+	// solid, variable edges dashed and wildcard edges dotted; a node whose static children are indexed is drawn with a
+	// double border.
+	ExportDOT
+)
+
+// Export writes the routes of router, and for ExportJSON and ExportDOT the tree built from them, to writer in the
+// format given.
+func Export(writer io.Writer, router Router, format ExportFormat) error {
+	switch format {
+	case ExportText:
+		return exportText(writer, router.Routes())
+	case ExportJSON:
+		return exportJSON(writer, router.Routes(), collectNodes(router))
+	case ExportDOT:
+		return exportDOT(writer, collectNodes(router))
+	default:
+		return ErrUnknownFormat
+	}
+}
@@ -0,0 +1,87 @@
+package httprouter
+
+import "net/http"
+
+// Group builds routes that share a path prefix and a middleware chain, and may carry their own NotFound and
+// MethodNotAllowed handlers. Groups nest: a group created from another extends its prefix and runs its middleware
+// inside the parent's. Build produces ordinary routes for Options.Routes, in the order they were added.
+type Group struct {
+	prefix           string
+	middleware       []func(http.Handler) http.Handler
+	notFound         http.Handler
+	methodNotAllowed http.Handler
+	entries          []groupEntry
+}
+type groupEntry struct {
+	route Route
+	group *Group // set instead of route for a nested group
+}
+
+// NewGroup starts a group of routes beneath prefix (e.g. "/api/v1"), whose handlers are wrapped by middleware: the
+// first listed is the outermost, so it sees each request first. The group's middleware is placed ahead of each route's
+// own, so it runs after the router's and before the route's.
+func NewGroup(prefix string, middleware ...func(http.Handler) http.Handler) *Group {
+	return &Group{prefix: prefix, middleware: middleware}
+}
+
+// Group starts a nested group beneath this one's prefix, whose middleware runs inside this group's.
+func (this *Group) Group(prefix string, middleware ...func(http.Handler) http.Handler) *Group {
+	nested := NewGroup(prefix, middleware...)
+	this.entries = append(this.entries, groupEntry{group: nested})
+	return nested
+}
+func (this *Group) Add(allowedMethods, paths string, handler http.Handler) *Group {
+	return this.Routes(ParseRoutes(allowedMethods, paths, handler)...)
+}
+func (this *Group) Routes(routes ...Route) *Group {
+	for _, route := range routes {
+		this.entries = append(this.entries, groupEntry{route: route})
+	}
+	return this
+}
+
+// NotFound answers requests beneath the group's prefix that the router finds no route for, once it has applied its
+// policies (host, trailing slash, case matching) as for any other request. Without it, the enclosing group's handler
+// answers them, or else the router's.
+func (this *Group) NotFound(value http.Handler) *Group {
+	this.notFound = value
+	return this
+}
+
+// MethodNotAllowed answers requests beneath the group's prefix for a path the router has routes for, but not with the
+// method requested; the Allow header is set as the router sets it. Without it, the enclosing group's handler answers
+// them, or else the router's.
+func (this *Group) MethodNotAllowed(value http.Handler) *Group {
+	this.methodNotAllowed = value
+	return this
+}
+
+// Build returns the group's routes with the prefix and middleware of this group and every enclosing one applied. The
+// routes of a group with its own NotFound or MethodNotAllowed handler carry them to the router, which answers with
+// them only while at least one of those routes is registered for the host of the request.
+func (this *Group) Build() []Route {
+	return this.build("", nil, nil)
+}
+func (this *Group) build(parentPrefix string, parentMiddleware []func(http.Handler) http.Handler, parentScope *groupScope) (routes []Route) {
+	prefix := parentPrefix + this.prefix
+	middleware := append(append([]func(http.Handler) http.Handler{}, parentMiddleware...), this.middleware...)
+	scope := parentScope
+	if this.notFound != nil || this.methodNotAllowed != nil {
+		scope = newGroupScope(prefix, this.notFound, this.methodNotAllowed, parentScope)
+	}
+
+	for _, entry := range this.entries {
+		if entry.group != nil {
+			routes = append(routes, entry.group.build(prefix, middleware, scope)...)
+			continue
+		}
+
+		route := entry.route
+		route.Path = prefix + route.Path
+		route.Middleware = append(append([]func(http.Handler) http.Handler{}, middleware...), route.Middleware...)
+		route.group = scope
+		routes = append(routes, route)
+	}
+
+	return routes
+}
@@ -8,6 +8,30 @@ func (this statusHandler) ServeHTTP(response http.ResponseWriter, _ *http.Reques
 	http.Error(response, http.StatusText(int(this)), int(this))
 }
 
+// headHandler serves a HEAD request through a GET handler, discarding whatever body it writes. The headers it sets,
+// Content-Length included, are sent as they are.
+type headHandler struct{ http.Handler }
+
+func (this headHandler) ServeHTTP(response http.ResponseWriter, request *http.Request) {
+	this.Handler.ServeHTTP(headResponseWriter{ResponseWriter: response}, request)
+}
+
+type headResponseWriter struct{ http.ResponseWriter }
+
+func (this headResponseWriter) Write(body []byte) (int, error) { return len(body), nil }
+func (this headResponseWriter) Unwrap() http.ResponseWriter    { return this.ResponseWriter }
+
+// wrap applies middleware to handler, the first listed outermost. A nil handler stays nil, so New still reports it.
+func wrap(handler http.Handler, middleware []func(http.Handler) http.Handler) http.Handler {
+	if handler == nil {
+		return nil
+	}
+	for index := len(middleware) - 1; index >= 0; index-- {
+		handler = middleware[index](handler)
+	}
+	return handler
+}
+
 func RecoveryHandler(response http.ResponseWriter, _ *http.Request, _ any) {
 	http.Error(response, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
 }
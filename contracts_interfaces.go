@@ -2,12 +2,36 @@ package httprouter
 
 import "net/http"
 
+// Router is what New builds: an http.Handler that routes each request, and that can also build the path of any
+// route it was given a Name for.
+type Router interface {
+	http.Handler
+
+	// URL returns the path of the route with the name given, with params (name/value pairs, e.g. "id", "42") in place
+	// of its variables and wildcard (under WildcardParam), each percent-encoded as needed. Every variable must be given
+	// one value that satisfies it, and nothing else may be given. The path is relative to any mount point (see
+	// MountPrefixFromContext) and never includes the route's Host.
+	URL(name string, params ...string) (string, error)
+
+	// Routes returns the routes the router was built from, in the order they were given (including those that
+	// Options.Mount and Group.Build produce), with their handlers as given rather than wrapped by middleware.
+	Routes() []Route
+
+	// Walk calls visit for every node of the routing tree, depth first, starting from the root of the tree that
+	// serves any host and then that of each host pattern in the order the patterns first appear. A node is visited
+	// once for each route that ends there, or once with the zero Route if none does.
+	Walk(visit func(Route, NodeInfo))
+}
+
 type routeResolver interface {
-	// Resolve returns an instance of http.Handler and a bitmask of the methods allowed at the matched path.
-	// If the http.Handler instance is not nil, the route was fully resolved and can be invoked.
-	// If the http.Handler instance is nil AND allowed > 0, the route was found, but the method isn't compatible (e.g. "POST /", but only a "GET /" was found).
-	// If the http.Handler instance is nil AND allowed == 0, the route was not found.
-	Resolve(method, path string) (http.Handler, Method)
+	// Resolve returns the endpoint registered for the method and path and a bitmask of the methods allowed at the matched path.
+	// If the endpoint is not nil, the route was fully resolved and its handler can be invoked.
+	// If the endpoint is nil AND allowed > 0, the route was found, but the method isn't compatible (e.g. "POST /", but only a "GET /" was found).
+	// If the endpoint is nil AND allowed == 0, the route was not found.
+	Resolve(method, path string) (*endpoint, Method)
+
+	// ResolveFold is Resolve with the registered text of the path compared without regard to ASCII case.
+	ResolveFold(method, path string) (*endpoint, Method)
 }
 
 type RecoveryFunc func(response http.ResponseWriter, request *http.Request, recovered any)
@@ -18,3 +42,27 @@ type Monitor interface {
 	MethodNotAllowed(*http.Request)
 	Recovered(*http.Request, any)
 }
+
+// RouteMonitor is an optional extension of Monitor. When the configured Monitor also implements it, each routed
+// request is additionally reported together with the Route it matched, which (unlike the raw request path) has a
+// bounded set of values suitable for labeling metrics.
+type RouteMonitor interface {
+	RoutedTo(*http.Request, Route)
+}
+
+// RejectionMonitor is an optional extension of Monitor. When the configured Monitor also implements it, each request
+// the router answers itself with a status that Monitor has no method for is reported with that status: 400 for a
+// path its EncodedSlashPolicy refuses or a version it has no route for, 406 for such a version asked for through the
+// Accept header (see VersionSelector), and 501 for a method no route registers (see Options.NotImplemented). The
+// status is the one the router's default handler for the case answers with, whatever a configured handler answers
+// instead. A request whose route requires predicates that don't hold is reported to Monitor.NotFound.
+type RejectionMonitor interface {
+	Rejected(*http.Request, int)
+}
+
+// ReloadMonitor is an optional extension of Monitor. When the configured Monitor also implements it, each attempt to
+// change the routes of a ReloadableRouter (Reload, Add or Remove) is reported: with a nil error to the Monitor of the
+// routes now served, or with the error that rejected the new routes to the Monitor of those still served.
+type ReloadMonitor interface {
+	Reloaded(error)
+}
@@ -3,9 +3,10 @@ package httprouter
 import (
 	"net/http"
 	"strings"
+	"sync"
 )
 
-type Method uint16
+type Method uint64
 
 func ParseMethods(value string) Method {
 	var parsed Method
@@ -18,13 +19,59 @@ func ParseMethods(value string) Method {
 }
 func ParseMethod(value string) Method {
 	value = strings.ToUpper(strings.TrimSpace(value))
+
+	methodRegistry.RLock()
+	defer methodRegistry.RUnlock()
 	if parsed, found := availableMethods[value]; found {
 		return parsed
 	}
 
 	return MethodNone
 }
+
+// RegisterMethod makes a method beyond the nine built in (e.g. WebDAV's "PROPFIND" or "MKCOL", "PURGE", "QUERY")
+// routable and returns the Method to register routes under; from then on ParseMethod recognizes it and it appears in
+// Allow headers. Registering a name again returns the Method it already has. The registry is shared by every router
+// in the process, so methods should be registered (e.g. from an init function) before the routers that use them are
+// built. A name must be an uppercase token (letters, digits, '-' and '_'), and there is room for 53 of them.
+func RegisterMethod(name string) (Method, error) {
+	if !isMethodName(name) {
+		return MethodNone, ErrInvalidMethod
+	}
+
+	methodRegistry.Lock()
+	defer methodRegistry.Unlock()
+
+	if existing, found := availableMethods[name]; found {
+		return existing, nil
+	}
+	if len(orderedMethods) >= maxMethods {
+		return MethodNone, ErrTooManyMethods
+	}
+
+	method := Method(1) << (len(orderedMethods) + 1) // bit zero is MethodNone
+	orderedMethods = append(orderedMethods, method)
+	methodValues[method] = name
+	availableMethods[name] = method
+	registeredMethods |= method
+	return method, nil
+}
+func isMethodName(name string) bool {
+	if len(name) == 0 || name == methodAnyName {
+		return false
+	}
+	for index := 0; index < len(name); index++ {
+		if character := name[index]; (character < 'A' || character > 'Z') && !isDigit(character) && character != '-' && character != '_' {
+			return false
+		}
+	}
+	return true
+}
+
 func (this Method) String() string {
+	methodRegistry.RLock()
+	defer methodRegistry.RUnlock()
+
 	var result string
 
 	for _, key := range orderedMethods {
@@ -39,16 +86,31 @@ func (this Method) String() string {
 		result += methodValues[key]
 	}
 
+	if this&MethodAny == MethodAny {
+		if len(result) > 0 {
+			result += pipeDelimiter
+		}
+		result += methodAnyName
+	}
+
 	return result
 }
 func (this Method) GoString() string { return this.String() }
 func (this Method) HeaderValue() string {
-	if int(this) < len(allowHeaderValues) {
+	if this < Method(len(allowHeaderValues)) {
 		return allowHeaderValues[this]
 	}
-	return this.buildHeaderValue()
+	if cached, found := customHeaderValues.Load(this); found {
+		return cached.(string)
+	}
+	value := this.buildHeaderValue()
+	customHeaderValues.Store(this, value)
+	return value
 }
 func (this Method) buildHeaderValue() string {
+	methodRegistry.RLock()
+	defer methodRegistry.RUnlock()
+
 	var result strings.Builder
 	for _, key := range orderedMethods {
 		if key&this != key {
@@ -64,6 +126,7 @@ func (this Method) buildHeaderValue() string {
 
 // allowHeaderValues caches every possible Allow-header string keyed by the Method bitmask. The 9 method bits
 // occupy 1<<1..1<<9, so every reachable union is < 1<<10; precomputing at init keeps the 405 path allocation-free.
+// Unions involving registered methods are cached in customHeaderValues as they are first needed.
 var allowHeaderValues = func() (cache [1 << 10]string) {
 	for combination := range cache {
 		cache[combination] = Method(combination).buildHeaderValue()
@@ -71,6 +134,52 @@ var allowHeaderValues = func() (cache [1 << 10]string) {
 	return cache
 }()
 
+var customHeaderValues sync.Map // of Method to string
+
+// methodIndex returns the bit position of the named method, which also places its endpoint in methodHandlers, and
+// whether the method is known at all. The built-in methods are resolved without consulting the registry.
+func methodIndex(method string) (int, bool) {
+	switch method {
+	case http.MethodGet:
+		return 1, true
+	case http.MethodHead:
+		return 2, true
+	case http.MethodPost:
+		return 3, true
+	case http.MethodPut:
+		return 4, true
+	case http.MethodDelete:
+		return 5, true
+	case http.MethodConnect:
+		return 6, true
+	case http.MethodOptions:
+		return 7, true
+	case http.MethodTrace:
+		return 8, true
+	case http.MethodPatch:
+		return 9, true
+	}
+
+	methodRegistry.RLock()
+	defer methodRegistry.RUnlock()
+	if parsed, found := availableMethods[method]; found && parsed&registeredMethods != 0 {
+		return bitIndex(parsed), true
+	}
+	return 0, false
+}
+func registeredMethodsSnapshot() Method {
+	methodRegistry.RLock()
+	defer methodRegistry.RUnlock()
+	return registeredMethods
+}
+func bitIndex(method Method) (index int) {
+	for method > 1 {
+		method >>= 1
+		index++
+	}
+	return index
+}
+
 ////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
 
 const (
@@ -96,7 +205,19 @@ const (
 		MethodPatch
 )
 
+// MethodAny registers a catch-all route: it serves every request method, registered or not, for which the same path
+// has no route of its own. It is parsed from and written as "ANY".
+const MethodAny Method = 1 << 63
+
+const (
+	methodAnyName = "ANY"
+	maxMethods    = 62 // the bits between MethodNone and MethodAny
+)
+
 var (
+	methodRegistry    sync.RWMutex // guards the following, which RegisterMethod extends
+	registeredMethods Method       // the methods added by RegisterMethod
+
 	orderedMethods = []Method{
 		MethodGet,
 		MethodHead,
@@ -132,5 +253,6 @@ var (
 		http.MethodOptions: MethodOptions,
 		http.MethodTrace:   MethodTrace,
 		http.MethodPatch:   MethodPatch,
+		methodAnyName:      MethodAny,
 	}
 )
@@ -0,0 +1,61 @@
+package httprouter
+
+import "context"
+
+// Param is a single value captured from the request path: a ":name" segment under its name, or the remainder matched
+// by a trailing "*" under WildcardParam. Raw is the value as it appeared in the (canonically encoded) path; Value is
+// the same unless the EncodedSlashPolicy decodes captured values.
+type Param struct {
+	Name  string
+	Value string
+	Raw   string
+}
+
+// Params holds the captured values of a resolved route in path order. Each value is also available from
+// http.Request.PathValue under the same name.
+type Params []Param
+
+func (this Params) Get(name string) string {
+	value, _ := this.Lookup(name)
+	return value
+}
+func (this Params) Lookup(name string) (string, bool) {
+	for _, param := range this {
+		if param.Name == name {
+			return param.Value, true
+		}
+	}
+	return "", false
+}
+
+// ParamsFromContext returns the values captured while routing the request that owns ctx, or nil if the matched route
+// has no variable or wildcard segments.
+func ParamsFromContext(ctx context.Context) Params {
+	if value, ok := ctx.Value(routeContextKey{}).(*routeContext); ok {
+		return value.params
+	}
+	return nil
+}
+
+// RouteFromContext returns the Route matched for the request that owns ctx and the pattern it was matched under
+// (e.g. "GET /users/:id"), or false if the request wasn't routed. The pattern is also set on http.Request.Pattern.
+func RouteFromContext(ctx context.Context) (route Route, pattern string, ok bool) {
+	if value, ok := ctx.Value(routeContextKey{}).(*routeContext); ok {
+		return value.route, value.pattern, true
+	}
+	return Route{}, "", false
+}
+
+// MountPrefixFromContext returns the path prefix stripped from the request that owns ctx before it reached a handler
+// mounted with Options.Mount (e.g. "/admin", or "/tenants/acme/admin" for a prefix with variables), including those of
+// any mounts enclosing it, or "" if the request didn't pass through a mount. Prepending it to a path the mounted handler
+// serves gives that path as the client sees it.
+func MountPrefixFromContext(ctx context.Context) string {
+	if value, ok := ctx.Value(mountContextKey{}).(*mountContext); ok {
+		return value.prefix
+	}
+	return ""
+}
+
+// WildcardParam is the name under which the remainder matched by a trailing "*" is captured.
+const WildcardParam = "*"
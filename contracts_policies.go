@@ -0,0 +1,94 @@
+package httprouter
+
+// TrailingSlashPolicy decides what happens when a request path differs from a registered route only by a trailing
+// slash ("/users/" vs "/users"), including a wildcard route's bare prefix ("/files" vs "/files/*"). An exact match
+// always wins; the policy only applies to a request that would otherwise not be found. Under either policy but
+// TrailingSlashStrict, a request for the unregistered spelling with a method the route doesn't allow is answered as
+// the registered spelling would be, 405 with its Allow header; CaseMatchingPolicy treats a case-folded spelling the
+// same way.
+type TrailingSlashPolicy uint8
+
+const (
+	// TrailingSlashStrict treats the two spellings as different paths, so the unregistered one is not found.
+	TrailingSlashStrict TrailingSlashPolicy = iota
+
+	// TrailingSlashRedirect sends the client to the registered spelling, keeping the query string: 301 for GET and
+	// HEAD, 308 for every other method so the method and body are repeated.
+	TrailingSlashRedirect
+
+	// TrailingSlashTolerant serves the unregistered spelling as if it were the registered one.
+	TrailingSlashTolerant
+)
+
+// CaseMatchingPolicy decides whether the text a route registers must match the request path in case. It applies to
+// static segments and to the literal text around variables; captured variable and wildcard values are always passed
+// on exactly as received. An exact-case match always wins.
+type CaseMatchingPolicy uint8
+
+const (
+	// CaseSensitive compares registered text byte for byte, so "/Users" does not find "/users".
+	CaseSensitive CaseMatchingPolicy = iota
+
+	// CaseInsensitive serves a path that matches a route only when case is ignored as if it had been spelled as
+	// registered.
+	CaseInsensitive
+
+	// CaseInsensitiveRedirect sends the client to the registered spelling instead, keeping the query string and the
+	// case of captured values: 301 for GET and HEAD, 308 for every other method.
+	CaseInsensitiveRedirect
+)
+
+// EncodedSlashPolicy decides how an encoded slash ("%2F") in the request path is treated. Paths are matched in their
+// percent-encoded form, so by default "%2F" is an ordinary part of a segment (as in "/packages/@scope%2Fpkg" for
+// "/packages/:name") and captured values are passed on still encoded.
+type EncodedSlashPolicy uint8
+
+const (
+	// EncodedSlashPreserve matches "%2F" as part of a segment and passes captured values on as they appear in the path.
+	EncodedSlashPreserve EncodedSlashPolicy = iota
+
+	// EncodedSlashDecodeValues matches "%2F" as part of a segment, but percent-decodes captured values (so ":name"
+	// captures "@scope/pkg" from "@scope%2Fpkg"). Param.Raw keeps the encoded form. Decoding happens after the path
+	// is normalized, so a path whose values would decode to "." or ".." segments ("/files/..%2Fetc") is answered with
+	// the BadRequest handler (400 by default) instead.
+	EncodedSlashDecodeValues
+
+	// EncodedSlashDecodePath decodes "%2F" before matching, making it a segment boundary like any other slash, and
+	// percent-decodes captured values as EncodedSlashDecodeValues does.
+	EncodedSlashDecodePath
+
+	// EncodedSlashReject answers a request whose path contains "%2F" with the BadRequest handler (400 by default).
+	EncodedSlashReject
+)
+
+// PathIrregularity identifies the ways a request path can spell a route other than canonically. Values combine as a
+// bitmask so one policy can be applied to several at once.
+type PathIrregularity uint8
+
+const (
+	// PathDuplicateSlashes is an empty segment inside the path: "/users//42" for "/users/42".
+	PathDuplicateSlashes PathIrregularity = 1 << iota
+
+	// PathDotSegments is a "." or ".." segment (also percent-encoded, as in "%2e%2e"): "/a/../users/./42" for
+	// "/users/42". A ".." never climbs above the root.
+	PathDotSegments
+
+	// PathTrailingDots is a segment ending in dots, which some file systems ignore: "/files/report.pdf." for
+	// "/files/report.pdf". Segments made of nothing but dots are left alone.
+	PathTrailingDots
+)
+
+// NormalizationPolicy decides what happens to a request whose path has an irregularity.
+type NormalizationPolicy uint8
+
+const (
+	// NormalizeNone routes the path exactly as received, which is the default for every irregularity.
+	NormalizeNone NormalizationPolicy = iota
+
+	// NormalizeRoute routes the canonical form of the path without telling the client.
+	NormalizeRoute
+
+	// NormalizeRedirect sends the client to the canonical form of the path, keeping the query string: 301 for GET and
+	// HEAD, 308 for every other method.
+	NormalizeRedirect
+)
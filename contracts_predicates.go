@@ -0,0 +1,59 @@
+package httprouter
+
+import (
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// Predicate is a condition on a request, beyond its method and path, that a Route can require. Routes sharing a
+// method and path (and version) are told apart by their predicates: the router serves the first of them, in
+// registration order, whose predicates all hold, and answers with the PredicateMismatch handler (by default, as
+// not found) when none do. Two routes for the same method and path may not require an identical set of predicates.
+type Predicate struct {
+	key   string // identifies the condition, so identical predicate sets can be recognized
+	match func(*http.Request) bool
+}
+
+// HeaderEquals requires the named header to have the value given, compared without regard to case.
+func HeaderEquals(name, value string) Predicate {
+	name = http.CanonicalHeaderKey(name)
+	return Predicate{key: "header " + name + "=" + value, match: func(request *http.Request) bool {
+		return strings.EqualFold(request.Header.Get(name), value)
+	}}
+}
+
+// HeaderMatches requires the named header to have a value matching the regular expression given, anywhere in the
+// value unless anchored. An expression that doesn't compile is reported by New as ErrInvalidPredicate.
+func HeaderMatches(name, expression string) Predicate {
+	name = http.CanonicalHeaderKey(name)
+	compiled, err := regexp.Compile(expression)
+	if err != nil {
+		return Predicate{key: "header " + name + "~" + expression}
+	}
+	return Predicate{key: "header " + name + "~" + expression, match: func(request *http.Request) bool {
+		return compiled.MatchString(request.Header.Get(name))
+	}}
+}
+
+// QueryPresent requires the query string to have the named parameter, with any value.
+func QueryPresent(name string) Predicate {
+	return Predicate{key: "query " + name, match: func(request *http.Request) bool {
+		return request.URL != nil && request.URL.Query().Has(name)
+	}}
+}
+
+// QueryEquals requires the named query parameter to have the value given.
+func QueryEquals(name, value string) Predicate {
+	return Predicate{key: "query " + name + "=" + value, match: func(request *http.Request) bool {
+		return request.URL != nil && request.URL.Query().Get(name) == value
+	}}
+}
+
+// PredicateFunc requires match to report true. As functions cannot be compared, the name identifies the condition.
+func PredicateFunc(name string, match func(*http.Request) bool) Predicate {
+	if match == nil {
+		return Predicate{key: "func " + name}
+	}
+	return Predicate{key: "func " + name, match: match}
+}
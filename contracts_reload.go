@@ -0,0 +1,118 @@
+package httprouter
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"sync/atomic"
+)
+
+// ReloadableRouter is a Router whose routes, and the rest of its configuration, can be replaced while it serves, all
+// at once (Reload) or a few routes at a time (Add and Remove). Each request is routed entirely by the routes in place
+// when it arrived, so requests in flight during a change finish as they began, and requests that arrive after it
+// returns see only the new routes. Routing takes no lock.
+type ReloadableRouter struct {
+	current atomic.Pointer[builtRouter]
+	reload  sync.Mutex // serializes changes, so each is built from the routes of the one before
+}
+
+// NewReloadable builds a ReloadableRouter from options, just as New builds a Router.
+func NewReloadable(options ...Option) (*ReloadableRouter, error) {
+	router, err := build(append(options[:len(options):len(options)], trackRequests))
+	if err != nil {
+		return nil, err
+	}
+
+	reloadable := &ReloadableRouter{}
+	reloadable.current.Store(router)
+	return reloadable, nil
+}
+
+// Reload builds a router from options, which replace (rather than add to) those given before, and swaps it in for
+// the current one once it is complete. If the options are rejected, the error is returned and the current routes go
+// on serving. Either way, the outcome is reported to the Monitor if it implements ReloadMonitor.
+func (this *ReloadableRouter) Reload(options ...Option) error {
+	return this.change(func(*builtRouter) (*builtRouter, error) {
+		return build(append(options[:len(options):len(options)], trackRequests))
+	})
+}
+
+// Add registers routes in addition to those served, copying only the part of the routing tree they are added to. If
+// any of them is rejected, none is added and the error is returned. The outcome is reported as a Reload's would be.
+func (this *ReloadableRouter) Add(routes ...Route) error {
+	return this.change(func(current *builtRouter) (*builtRouter, error) {
+		return current.with(routes)
+	})
+}
+
+// Remove stops serving routes, each of which must match (by methods, host, path, version and predicates) a route that
+// is served, or ErrRouteNotFound is returned and none is removed. Requests already routed to them run to completion.
+// The outcome is reported as a Reload's would be.
+func (this *ReloadableRouter) Remove(routes ...Route) error {
+	_, err := this.remove(routes)
+	return err
+}
+
+// RemoveAndDrain is Remove, after which it waits for requests already routed to the routes removed to finish, or
+// for ctx to be done, in which case it returns ctx.Err() (the routes stay removed). A request is waited for only once
+// its route's handler has begun: one resolved just before the routes were removed that has yet to reach the handler
+// is not, and may still begin after RemoveAndDrain returns.
+func (this *ReloadableRouter) RemoveAndDrain(ctx context.Context, routes ...Route) error {
+	handlers, err := this.remove(routes)
+	if err != nil {
+		return err
+	}
+
+	for _, handler := range handlers {
+		if tracked, ok := handler.(*trackedHandler); ok {
+			if err = tracked.drain(ctx); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+func (this *ReloadableRouter) remove(routes []Route) (handlers []http.Handler, err error) {
+	err = this.change(func(current *builtRouter) (updated *builtRouter, err error) {
+		updated, handlers, err = current.without(routes)
+		return updated, err
+	})
+	return handlers, err
+}
+
+// change swaps in the router that rebuild returns from the current one, unless it fails, and reports the outcome.
+func (this *ReloadableRouter) change(rebuild func(*builtRouter) (*builtRouter, error)) error {
+	this.reload.Lock()
+	defer this.reload.Unlock()
+
+	current := this.current.Load()
+	updated, err := rebuild(current)
+	if err != nil {
+		reportReload(current.config.Monitor, err)
+		return err
+	}
+
+	this.current.Store(updated)
+	reportReload(updated.config.Monitor, nil)
+	return nil
+}
+
+func (this *ReloadableRouter) ServeHTTP(response http.ResponseWriter, request *http.Request) {
+	this.current.Load().ServeHTTP(response, request)
+}
+func (this *ReloadableRouter) URL(name string, params ...string) (string, error) {
+	return this.current.Load().URL(name, params...)
+}
+func (this *ReloadableRouter) Routes() []Route {
+	return this.current.Load().Routes()
+}
+func (this *ReloadableRouter) Walk(visit func(Route, NodeInfo)) {
+	this.current.Load().Walk(visit)
+}
+
+func trackRequests(this *configuration) { this.trackRequests = true }
+func reportReload(monitor Monitor, err error) {
+	if reloadMonitor, ok := monitor.(ReloadMonitor); ok {
+		reloadMonitor.Reloaded(err)
+	}
+}
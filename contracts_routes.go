@@ -6,28 +6,82 @@ import (
 )
 
 type Route struct {
+	Name           string // optional: identifies the route to Router.URL
 	AllowedMethods Method
+	Host           string // optional: an exact host, "{variable}.example.com" or "*.example.com"; see hostPattern
 	Path           string
+	Version        string      // optional: the API version served, when several routes share a method and path; see VersionSelector
+	Predicates     []Predicate // optional: conditions the request must also meet; see Predicate
 	Handler        http.Handler
+	Middleware     []func(http.Handler) http.Handler // optional: wraps Handler inside any router-level middleware, the first listed outermost
+
+	group *groupScope // set by Group.Build when the route's group (or one enclosing it) answers requests of its own
 }
 
 func ParseRoutes(allowedMethods string, paths string, handler http.Handler) (routes []Route) {
 	paths = strings.TrimSpace(paths)
 
-	for _, item := range strings.Split(paths, pipeDelimiter) {
+	for _, item := range splitOutsideBraces(paths, pipeDelimiter) {
 		routes = append(routes, ParseRoute(allowedMethods, item, handler))
 	}
 
 	return routes
 }
+
+// ParseRoute parses a route for the methods given (e.g. "GET|HEAD"), at a path that may be preceded by a host
+// ("api.example.com/users"), in which case the path begins at the first slash. Text before the first slash is taken
+// for a host only if it looks like one (see isHostPrefix); otherwise it stays part of the path, which New rejects as
+// malformed, so a path missing its leading slash ("users/:id") isn't registered under a host by mistake.
 func ParseRoute(allowedMethods string, path string, handler http.Handler) Route {
+	var host string
+	path = strings.TrimSpace(path)
+	if slash := strings.IndexByte(path, '/'); slash > 0 && isHostPrefix(path[:slash]) {
+		host, path = path[:slash], path[slash:]
+	}
+
 	return Route{
 		AllowedMethods: ParseMethods(allowedMethods),
-		Path:           strings.TrimSpace(path),
+		Host:           host,
+		Path:           path,
 		Handler:        handler,
 	}
 }
-func (this Route) String() string   { return this.AllowedMethods.String() + " " + this.Path }
+
+// isHostPrefix reports whether value looks like a host rather than the start of a path: it has a dot or a variable
+// label, ends in a port, or is "localhost".
+func isHostPrefix(value string) bool {
+	if strings.ContainsAny(value, ".{") || strings.EqualFold(value, "localhost") {
+		return true
+	}
+	colon := strings.LastIndexByte(value, ':')
+	if colon <= 0 || colon == len(value)-1 {
+		return false
+	}
+	for index := colon + 1; index < len(value); index++ {
+		if !isDigit(value[index]) {
+			return false
+		}
+	}
+	return true
+}
+func (this Route) String() string   { return this.AllowedMethods.String() + " " + this.Host + this.Path }
 func (this Route) GoString() string { return this.String() }
 
 const pipeDelimiter = "|"
+
+// routeIndex returns the position in routes of the route given, or -1. Routes carry handlers and predicates that can't
+// be compared, so they are told apart by everything that identifies a registration: methods, host, path, version and
+// predicates.
+func routeIndex(routes []Route, route Route) int {
+	for index, candidate := range routes {
+		if candidate.String() == route.String() && candidate.Version == route.Version &&
+			predicateKeys(candidate.Predicates) == predicateKeys(route.Predicates) {
+			return index
+		}
+	}
+	return -1
+}
+func predicateKeys(predicates []Predicate) string {
+	key, _ := newCandidateKey(predicates)
+	return key
+}
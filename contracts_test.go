@@ -29,6 +29,15 @@ func TestParseRoutes(t *testing.T) {
 		Route{AllowedMethods: MethodHead | MethodOptions, Path: "/Path/To/Document"},
 		Route{AllowedMethods: MethodHead | MethodOptions, Path: "/Document/*"})
 
+	assertParsedRoutes(t, "GET", "/:kind{draft|final}|/archive",
+		Route{AllowedMethods: MethodGet, Path: "/:kind{draft|final}"},
+		Route{AllowedMethods: MethodGet, Path: "/archive"})
+
+	assertParsedRoutes(t, "GET", "{tenant}.example.com/users/:id | api.example.com:8443/",
+		Route{AllowedMethods: MethodGet, Host: "{tenant}.example.com", Path: "/users/:id"},
+		Route{AllowedMethods: MethodGet, Host: "api.example.com:8443", Path: "/"})
+
+	Assert(t).That(ParseRoute("GET", "api.example.com/users", nil).String()).Equals("GET api.example.com/users")
 	route := ParseRoute("GET|HEAD", "/document", nil)
 	Assert(t).That(route.String()).Equals("GET|HEAD /document")
 	Assert(t).That(route.String()).Equals(route.GoString())
@@ -61,6 +70,14 @@ func (this *Assertion) IsNil() {
 		this.Equals(nil)
 	}
 }
+
+// Wraps asserts that the actual value is a *RouteError wrapping the expected error.
+func (this *Assertion) Wraps(expected error) {
+	this.Helper()
+	if routeError, ok := this.actual.(*RouteError); !ok || routeError.Err != expected {
+		this.Errorf("\nExpected: a *RouteError wrapping %#v\nActual:   %#v", expected, this.actual)
+	}
+}
 func (this *Assertion) Equals(expected any) {
 	this.Helper()
 	if !reflect.DeepEqual(this.actual, expected) {
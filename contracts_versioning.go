@@ -0,0 +1,78 @@
+package httprouter
+
+import (
+	"net/http"
+	"strings"
+)
+
+// VersionSelector reads the API version a request asks for. Routes registered for the same method and path may each
+// declare a different Route.Version; the router serves the one the request asks for, the configured default version
+// if it asks for none, or the latest version the path has if there is no default or the path doesn't have it. A
+// request asking for a version the path doesn't have is answered 406 (NotAcceptable handler) when the version came
+// from the Accept header and 400 (BadRequest handler) otherwise. Every response from a versioned path varies by the
+// header the version is read from.
+type VersionSelector struct {
+	source versionSource
+	name   string
+}
+
+// VersionFromAccept reads the version from a vendor media type in the Accept header:
+// "application/vnd.acme.v2+json" asks for version "2" of vendor "acme".
+func VersionFromAccept(vendor string) VersionSelector {
+	return VersionSelector{source: versionFromAccept, name: "application/vnd." + vendor + ".v"}
+}
+
+// VersionFromHeader reads the version from a header of its own, such as "X-API-Version: 2".
+func VersionFromHeader(name string) VersionSelector {
+	return VersionSelector{source: versionFromHeader, name: http.CanonicalHeaderKey(name)}
+}
+
+// VersionFromQuery reads the version from a query parameter, such as "?version=2".
+func VersionFromQuery(name string) VersionSelector {
+	return VersionSelector{source: versionFromQuery, name: name}
+}
+
+func (this VersionSelector) requested(request *http.Request) (version string) {
+	switch this.source {
+	case versionFromAccept:
+		for _, value := range request.Header.Values("Accept") {
+			for _, mediaRange := range strings.Split(value, ",") {
+				mediaType, _, _ := strings.Cut(strings.TrimSpace(mediaRange), ";")
+				if suffix, found := strings.CutPrefix(strings.TrimSpace(mediaType), this.name); found {
+					version, _, _ = strings.Cut(suffix, "+")
+					return version
+				}
+			}
+		}
+	case versionFromHeader:
+		return strings.TrimSpace(request.Header.Get(this.name))
+	case versionFromQuery:
+		if request.URL != nil {
+			return strings.TrimSpace(request.URL.Query().Get(this.name))
+		}
+	}
+	return ""
+}
+func (this VersionSelector) vary() string {
+	switch this.source {
+	case versionFromAccept:
+		return "Accept"
+	case versionFromHeader:
+		return this.name
+	default:
+		return "" // the query string is part of the URL, which caches key on already
+	}
+}
+
+type versionSource uint8
+
+const (
+	versionFromNowhere versionSource = iota
+	versionFromAccept
+	versionFromHeader
+	versionFromQuery
+)
+
+// VersionLatest names the highest version a path has, whether asked for by a request or configured as the default.
+// Versions are ordered by their dot-separated numeric parts ("2" < "2.1" < "10"), ignoring a leading "v".
+const VersionLatest = "latest"
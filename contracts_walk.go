@@ -0,0 +1,33 @@
+package httprouter
+
+// NodeInfo describes a node of the routing tree, as built from the routes and compacted, to the function given to
+// Router.Walk.
+type NodeInfo struct {
+	Host     string       // the host pattern of the routes in the node's tree; empty for the tree that serves any host
+	Depth    int          // the number of nodes between this one and the root of its tree, which has depth 0
+	Fragment string       // the path text the node matches: static segments (without the leading slash), a variable segment as written, or "*"
+	Kind     FragmentKind // how the node matches its fragment
+	Indexed  bool         // the node is found through its parent's index of static children, kept once they are many
+	Methods  Method       // the methods allowed at the node; zero if no route ends here
+}
+
+// FragmentKind tells how a node of the routing tree matches its fragment of the path: static text literally, a
+// variable a whole segment (subject to any constraint), and the wildcard whatever remains.
+type FragmentKind uint8
+
+const (
+	FragmentStatic FragmentKind = iota
+	FragmentVariable
+	FragmentWildcard
+)
+
+func (this FragmentKind) String() string {
+	switch this {
+	case FragmentVariable:
+		return "variable"
+	case FragmentWildcard:
+		return "wildcard"
+	default:
+		return "static"
+	}
+}
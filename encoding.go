@@ -0,0 +1,119 @@
+package httprouter
+
+import "strings"
+
+// Paths are compared in their percent-encoded form, never decoded, after both the route and the request have been put
+// into the same canonical encoding (RFC 3986, section 6.2.2): an encoded unreserved character is decoded ("%7E" is
+// "~"), every other encoded octet has its hex digits uppercased ("%c3%a9" is "%C3%A9"), and any octet that may not
+// appear in a path segment as is — non-ASCII text in particular — is encoded ("é" is "%C3%A9"). Reserved characters
+// keep their encoding, so an encoded "/" ("%2F") is part of a segment rather than a separator, and captured values
+// are passed on in this canonical encoding unless the EncodedSlashPolicy has them decoded.
+
+// mayNeedEncoding reports whether normalizeEncoding could change value in a way that matters to routing: only an
+// encoded octet or a non-ASCII byte can, since any other ASCII byte that a path segment may not hold as is can never
+// match a registered route, encoded or not. It is the only cost a plain path pays (see BenchmarkRouterLongStatic), so
+// it looks for '%' with strings.IndexByte and for a high bit eight bytes at a time, never consulting a table.
+func mayNeedEncoding(value string) bool {
+	if strings.IndexByte(value, '%') >= 0 {
+		return true
+	}
+
+	var bits uint64
+	for ; len(value) >= 8; value = value[8:] {
+		bits |= uint64(value[0]) | uint64(value[1])<<8 | uint64(value[2])<<16 | uint64(value[3])<<24 |
+			uint64(value[4])<<32 | uint64(value[5])<<40 | uint64(value[6])<<48 | uint64(value[7])<<56
+	}
+	for index := 0; index < len(value); index++ {
+		bits |= uint64(value[index])
+	}
+	return bits&0x8080808080808080 != 0
+}
+
+// normalizeEncoding returns value in canonical encoding, or value itself (without allocating) if it already is. It
+// reports false, leaving value alone, if a '%' is not followed by two hex digits.
+func normalizeEncoding(value string) (string, bool) {
+	canonical := true
+	for index := 0; index < len(value) && canonical; index++ {
+		if character := value[index]; character == '%' {
+			if index+2 >= len(value) || !isHex(value[index+1]) || !isHex(value[index+2]) {
+				return value, false
+			}
+			decoded := unhex(value[index+1])<<4 | unhex(value[index+2])
+			canonical = !unreservedCharacters[decoded] && isUpperHex(value[index+1]) && isUpperHex(value[index+2])
+			index += 2
+		} else {
+			canonical = plainCharacters[character]
+		}
+	}
+	if canonical {
+		return value, true
+	}
+
+	buffer := make([]byte, 0, len(value)+len(value)/2)
+	for index := 0; index < len(value); index++ {
+		character := value[index]
+		if character == '%' {
+			if index+2 >= len(value) || !isHex(value[index+1]) || !isHex(value[index+2]) {
+				return value, false
+			}
+			character = unhex(value[index+1])<<4 | unhex(value[index+2])
+			index += 2
+			if unreservedCharacters[character] {
+				buffer = append(buffer, character)
+				continue
+			}
+		} else if plainCharacters[character] {
+			buffer = append(buffer, character)
+			continue
+		}
+		buffer = append(buffer, '%', upperHexDigits[character>>4], upperHexDigits[character&0x0F])
+	}
+	return string(buffer), true
+}
+
+// normalizeLiteral validates and canonically encodes literal text from a route path. Non-ASCII text is accepted (and
+// encoded), but an ASCII character that is not legal in a path segment must already be percent-encoded, as must ':'
+// and '*', which route paths reserve for variables and wildcards.
+func normalizeLiteral(value string) (string, bool) {
+	for index := 0; index < len(value); index++ {
+		character := value[index]
+		if character == ':' || character == '*' || (character < 0x80 && character != '%' && !pathCharacters[character]) {
+			return value, false
+		}
+	}
+	return normalizeEncoding(value)
+}
+
+// pathCharacters marks the characters that may appear in a path segment without being percent-encoded: the RFC 3986
+// "pchar" set, less the '%' that introduces an encoded octet. unreservedCharacters marks those of them that canonical
+// encoding never encodes, and plainCharacters adds '/' to pathCharacters. Every byte of every request path is looked
+// up in them, so they are tables rather than functions.
+var pathCharacters, unreservedCharacters, plainCharacters = characterTables()
+
+func characterTables() (path, unreserved, plain [256]bool) {
+	for character := 0; character < 256; character++ {
+		value := byte(character)
+		unreserved[character] = isLetter(value) || isDigit(value) || strings.IndexByte("-._~", value) >= 0
+		path[character] = unreserved[character] || strings.IndexByte("!$&'()*+,;=:@", value) >= 0
+		plain[character] = path[character] || value == '/'
+	}
+	return path, unreserved, plain
+}
+func isDigit(character byte) bool {
+	return character >= '0' && character <= '9'
+}
+func isUpperHex(character byte) bool {
+	return isDigit(character) || (character >= 'A' && character <= 'F')
+}
+func unhex(character byte) byte {
+	switch {
+	case isDigit(character):
+		return character - '0'
+	case character >= 'a':
+		return character - 'a' + 10
+	default:
+		return character - 'A' + 10
+	}
+}
+
+const upperHexDigits = "0123456789ABCDEF"
@@ -0,0 +1,118 @@
+package httprouter
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+)
+
+// endpoint is what the tree stores for each method of each registered route: the handler to invoke together with the
+// parsed path it was registered under, from which the values of any variable or wildcard segments are read once it
+// resolves, and the pattern (e.g. "GET /users/:id") reported for requests routed to it. Routes declaring different
+// versions of, or predicates on, the same method and path are chained through next (see candidates.go).
+type endpoint struct {
+	route        Route
+	template     pathTemplate
+	handler      http.Handler
+	pattern      string
+	static       *routeContext // the context value of every request routed here, when none has values of its own
+	version      string
+	predicates   []Predicate
+	predicateKey string
+	next         *endpoint
+}
+
+func newEndpoint(route Route, template pathTemplate, predicateKey string, handler http.Handler) *endpoint {
+	return &endpoint{route: route, template: template, handler: handler, version: route.Version,
+		predicates: route.Predicates, predicateKey: predicateKey}
+}
+
+// forMethod returns a copy of this endpoint dedicated to a single method, so the pattern it reports is computed
+// once at registration rather than on every request.
+func (this *endpoint) forMethod(method Method) *endpoint {
+	dedicated := *this
+	dedicated.next = nil
+	if dedicated.pattern = this.route.Host + this.route.Path; method != MethodAny {
+		dedicated.pattern = method.String() + " " + dedicated.pattern // like http.ServeMux, a pattern for any method names none
+	}
+	dedicated.static = &routeContext{route: dedicated.route, pattern: dedicated.pattern}
+	return &dedicated
+}
+
+// forHead returns a copy of this endpoint chain that serves HEAD through its (GET) handlers, discarding the body.
+func (this *endpoint) forHead() *endpoint {
+	head := *this
+	head.handler = headHandler{Handler: this.handler}
+	if this.next != nil {
+		head.next = this.next.forHead()
+	}
+	return &head
+}
+
+// bind records the matched pattern on http.Request.Pattern and makes the values captured from path available through
+// http.Request.PathValue and the request context, percent-decoded if decode is set, after any values captured from
+// the host. Routes without variable or wildcard segments (on a host without variable labels) have nothing
+// request-specific to carry, so their requests share a context value built with the endpoint, and binding costs only
+// the context and request that carry it.
+func (this *endpoint) bind(request *http.Request, path string, decode bool, hostParams Params) *http.Request {
+	if this.template.captures == 0 && len(hostParams) == 0 {
+		request = withRouteContext(request, this.static)
+		request.Pattern = this.pattern
+		return request
+	}
+
+	params := this.template.params(path)
+	if len(hostParams) > 0 {
+		params = append(hostParams, params...)
+	}
+	state := &routeContext{route: this.route, pattern: this.pattern, path: path, params: params}
+	for index, param := range state.params {
+		state.params[index].Raw = param.Value
+		if !decode {
+			continue
+		} else if decoded, err := url.PathUnescape(param.Value); err == nil {
+			state.params[index].Value = decoded
+		}
+	}
+	request = withRouteContext(request, state)
+	request.Pattern = this.pattern
+	for _, param := range state.params {
+		request.SetPathValue(param.Name, param.Value)
+	}
+	return request
+}
+
+// withRouteContext returns a copy of request whose context carries state. The copy and its context are allocated
+// together, as routing pays for them on every request it serves.
+func withRouteContext(request *http.Request, state *routeContext) *http.Request {
+	routed := &routedRequest{context: routedContext{Context: request.Context(), state: state}}
+	routed.request = *request.WithContext(&routed.context)
+	return &routed.request
+}
+
+type routedRequest struct {
+	request http.Request
+	context routedContext
+}
+
+// routedContext is a context.WithValue for routeContextKey, which can be allocated along with the request it belongs
+// to.
+type routedContext struct {
+	context.Context
+	state *routeContext
+}
+
+func (this *routedContext) Value(key any) any {
+	if key == (routeContextKey{}) {
+		return this.state
+	}
+	return this.Context.Value(key)
+}
+
+type routeContextKey struct{}
+type routeContext struct {
+	route   Route
+	pattern string
+	path    string // the canonically encoded path the route was resolved against
+	params  Params
+}
@@ -0,0 +1,160 @@
+package httprouter
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+)
+
+// exportNode is a node of the routing tree as Router.Walk reports it, with the routes that end there gathered up.
+type exportNode struct {
+	info   NodeInfo
+	routes []Route
+	parent int  // the index of the parent node, or -1 for the root of a tree
+	wide   bool // the node's static children are indexed
+}
+
+// collectNodes gathers the nodes that Walk visits, in the order visited. Walk visits a node once per route that ends
+// there, one visit right after another, and a node's children right after it, so a node's parent is the closest
+// node before it that is one level up.
+func collectNodes(router Router) (nodes []exportNode) {
+	var ancestors []int // the index of the node at each depth along the current branch
+	router.Walk(func(route Route, info NodeInfo) {
+		if count := len(nodes); count > 0 && route.AllowedMethods != 0 && len(nodes[count-1].routes) > 0 &&
+			nodes[count-1].info == info {
+			nodes[count-1].routes = append(nodes[count-1].routes, route)
+			return
+		}
+
+		node := exportNode{info: info, parent: -1}
+		if route.AllowedMethods != 0 {
+			node.routes = []Route{route}
+		}
+		ancestors = append(ancestors[:info.Depth], len(nodes))
+		if info.Depth > 0 {
+			node.parent = ancestors[info.Depth-1]
+			nodes[node.parent].wide = nodes[node.parent].wide || info.Indexed
+		}
+		nodes = append(nodes, node)
+	})
+	return nodes
+}
+
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+
+func exportText(writer io.Writer, routes []Route) error {
+	table := tabwriter.NewWriter(writer, 0, 4, 2, ' ', 0)
+	_, _ = fmt.Fprintln(table, "METHODS\tPATTERN\tHANDLER")
+	for _, route := range routes {
+		_, _ = fmt.Fprintf(table, "%s\t%s\t%T\n", route.AllowedMethods, route.Host+route.Path, route.Handler)
+	}
+	return table.Flush()
+}
+
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+
+type exportedTable struct {
+	Routes []exportedRoute `json:"routes"`
+	Nodes  []exportedNode  `json:"nodes"`
+}
+type exportedRoute struct {
+	Name       string   `json:"name,omitempty"`
+	Methods    []string `json:"methods"`
+	Host       string   `json:"host,omitempty"`
+	Path       string   `json:"path"`
+	Version    string   `json:"version,omitempty"`
+	Predicates []string `json:"predicates,omitempty"`
+	Handler    string   `json:"handler"`
+}
+type exportedNode struct {
+	Host     string   `json:"host,omitempty"`
+	Depth    int      `json:"depth"`
+	Parent   int      `json:"parent"` // the index of the parent node in the list, or -1 for the root of a tree
+	Kind     string   `json:"kind"`
+	Fragment string   `json:"fragment"`
+	Indexed  bool     `json:"indexed"`
+	Wide     bool     `json:"wide"`
+	Methods  []string `json:"methods,omitempty"`
+	Routes   []int    `json:"routes,omitempty"` // the indexes in the list of routes of those ending at the node
+}
+
+func exportJSON(writer io.Writer, routes []Route, nodes []exportNode) error {
+	table := exportedTable{Routes: make([]exportedRoute, 0, len(routes)), Nodes: make([]exportedNode, 0, len(nodes))}
+	for _, route := range routes {
+		exported := exportedRoute{Name: route.Name, Methods: methodNames(route.AllowedMethods), Host: route.Host,
+			Path: route.Path, Version: route.Version, Handler: fmt.Sprintf("%T", route.Handler)}
+		for _, predicate := range route.Predicates {
+			exported.Predicates = append(exported.Predicates, predicate.key)
+		}
+		table.Routes = append(table.Routes, exported)
+	}
+
+	for _, node := range nodes {
+		exported := exportedNode{Host: node.info.Host, Depth: node.info.Depth, Parent: node.parent,
+			Kind: node.info.Kind.String(), Fragment: node.info.Fragment, Indexed: node.info.Indexed, Wide: node.wide,
+			Methods: methodNames(node.info.Methods)}
+		for _, route := range node.routes {
+			exported.Routes = append(exported.Routes, routeIndex(routes, route))
+		}
+		table.Nodes = append(table.Nodes, exported)
+	}
+
+	encoder := json.NewEncoder(writer)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(table)
+}
+func methodNames(methods Method) []string {
+	if methods == 0 {
+		return nil
+	}
+	return strings.Split(methods.String(), pipeDelimiter)
+}
+
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+
+func exportDOT(writer io.Writer, nodes []exportNode) error {
+	var builder strings.Builder
+	builder.WriteString("digraph routes {\n\trankdir=LR;\n\tnode [shape=box, fontname=monospace];\n")
+
+	for index, node := range nodes {
+		if node.parent < 0 {
+			if index > 0 {
+				builder.WriteString("\t}\n")
+			}
+			host := node.info.Host
+			if len(host) == 0 {
+				host = "any host"
+			}
+			fmt.Fprintf(&builder, "\tsubgraph cluster_%d {\n\t\tlabel=%q;\n", index, host)
+		}
+
+		label := "/" + node.info.Fragment
+		if node.info.Methods != 0 {
+			label += "\n" + node.info.Methods.String()
+		}
+		fmt.Fprintf(&builder, "\t\tn%d [label=%q", index, label)
+		if node.wide {
+			builder.WriteString(", peripheries=2")
+		}
+		builder.WriteString("];\n")
+
+		if node.parent >= 0 {
+			fmt.Fprintf(&builder, "\t\tn%d -> n%d [style=%s];\n", node.parent, index, edgeStyles[node.info.Kind])
+		}
+	}
+	if len(nodes) > 0 {
+		builder.WriteString("\t}\n")
+	}
+
+	builder.WriteString("}\n")
+	_, err := io.WriteString(writer, builder.String())
+	return err
+}
+
+var edgeStyles = map[FragmentKind]string{
+	FragmentStatic:   "solid",
+	FragmentVariable: "dashed",
+	FragmentWildcard: "dotted",
+}
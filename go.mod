@@ -1,3 +1,3 @@
 module github.com/smarty/httprouter
 
-go 1.22
+go 1.23
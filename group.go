@@ -0,0 +1,112 @@
+package httprouter
+
+import (
+	"net/http"
+	"strings"
+)
+
+// groupScope is what the router knows of a group with its own NotFound or MethodNotAllowed handler: the prefix its
+// routes lie beneath, and the handlers that answer the requests beneath it that none of the router's routes serve.
+// Group.Build stamps it on each of the group's routes, so it reaches the router with them and is kept, for as long as
+// any of them is registered, with the tree of their host (see routeTree.groups). The router consults it only once it
+// has applied every policy and found nothing to serve, so the requests it answers are reported as not found or not
+// allowed like any other.
+type groupScope struct {
+	template         pathTemplate
+	notFound         http.Handler // nil defers to the enclosing scope, if any, and then to the router
+	methodNotAllowed http.Handler // likewise
+	parent           *groupScope  // the nearest enclosing group that has a scope
+}
+
+func newGroupScope(prefix string, notFound, methodNotAllowed http.Handler, parent *groupScope) *groupScope {
+	template, err := parsePathTemplate(strings.TrimSuffix(prefix, "/"))
+	if err != nil {
+		return parent // New rejects every route beneath a malformed prefix anyway
+	}
+	return &groupScope{template: template, notFound: notFound, methodNotAllowed: methodNotAllowed, parent: parent}
+}
+
+// covers reports whether path is the group's prefix or lies beneath it, ignoring the case of literal text if fold is
+// set.
+func (this *groupScope) covers(path string, fold bool) bool {
+	for _, segment := range this.template.segments {
+		if len(path) == 0 || path[0] != '/' {
+			return false
+		}
+		end := strings.IndexByte(path[1:], '/') + 1
+		if end == 0 {
+			end = len(path)
+		}
+		current := path[1:end]
+		path = path[end:]
+
+		switch segment.kind {
+		case segmentWildcard:
+			return true
+		case segmentVariable:
+			if segment.matcher == nil && len(current) == 0 {
+				return false
+			} else if segment.matcher != nil && !segment.matcher.matches(current, fold) {
+				return false
+			}
+		default:
+			if (fold && !equalFoldASCII(current, segment.text)) || (!fold && current != segment.text) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// handler returns the handler that answers with status on behalf of this scope or the nearest enclosing one that has
+// one, or nil if none does.
+func (this *groupScope) handler(status int) http.Handler {
+	for scope := this; scope != nil; scope = scope.parent {
+		if status == http.StatusNotFound && scope.notFound != nil {
+			return scope.notFound
+		} else if status == http.StatusMethodNotAllowed && scope.methodNotAllowed != nil {
+			return scope.methodNotAllowed
+		}
+	}
+	return nil
+}
+
+// innermostScope returns the scope among scopes with the longest prefix that covers path, or nil if none does.
+func innermostScope(scopes []*groupScope, path string, fold bool) (innermost *groupScope) {
+	for _, scope := range scopes {
+		if (innermost == nil || len(scope.template.segments) > len(innermost.template.segments)) && scope.covers(path, fold) {
+			innermost = scope
+		}
+	}
+	return innermost
+}
+
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+
+// withGroups returns a copy of this tree that counts one more (delta 1) or one fewer (delta -1) route in scope and in
+// each scope enclosing it. The count is copied rather than changed, as the tree may be routing requests already.
+func (this routeTree) withGroups(scope *groupScope, delta int) routeTree {
+	if scope == nil {
+		return this
+	}
+
+	groups := make(map[*groupScope]int, len(this.groups)+1)
+	for existing, count := range this.groups {
+		groups[existing] = count
+	}
+	for ; scope != nil; scope = scope.parent {
+		if groups[scope] += delta; groups[scope] <= 0 {
+			delete(groups, scope)
+		}
+	}
+	this.groups = groups
+	return this
+}
+
+// scopes returns the group scopes with routes in this tree.
+func (this routeTree) scopes() (scopes []*groupScope) {
+	for scope := range this.groups {
+		scopes = append(scopes, scope)
+	}
+	return scopes
+}
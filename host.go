@@ -0,0 +1,161 @@
+package httprouter
+
+import (
+	"sort"
+	"strings"
+)
+
+// hostPattern matches the host of a request against the Host of a route, ignoring case, any port and a trailing dot.
+// A host is either an exact name ("api.example.com"), a name with variable labels ("{tenant}.example.com"), each of
+// which takes exactly one label and is captured like a path variable, or a name whose first label is "*"
+// ("*.example.com"), which takes one or more leading labels and captures nothing.
+type hostPattern struct {
+	source   string // the canonical spelling, which identifies the pattern
+	labels   []hostLabel
+	exact    bool
+	literals int
+}
+type hostLabel struct {
+	literal  string
+	name     string
+	wildcard bool
+}
+
+// parseHostPattern parses the Host of a route, reporting a malformed one as a *RouteError locating the label at fault
+// (which the caller completes with the route). The label is reported as canonically spelled, at its offset in that
+// spelling, which is its offset in the Host too unless lowercasing changed the length of text before it.
+func parseHostPattern(value string) (*hostPattern, error) {
+	pattern := &hostPattern{source: canonicalHost(value), exact: true}
+	if len(pattern.source) == 0 {
+		return nil, &RouteError{Fragment: value, Offset: 0, Err: ErrMalformedHost}
+	}
+
+	offset := 0
+	for index, label := range strings.Split(pattern.source, ".") {
+		switch {
+		case label == "*" && index == 0 && label != pattern.source:
+			pattern.labels = append(pattern.labels, hostLabel{wildcard: true})
+			pattern.exact = false
+		case strings.HasPrefix(label, "{") && strings.HasSuffix(label, "}") && isHostVariable(label[1:len(label)-1]):
+			pattern.labels = append(pattern.labels, hostLabel{name: label[1 : len(label)-1]})
+			pattern.exact = false
+		case isHostLiteral(label):
+			pattern.labels = append(pattern.labels, hostLabel{literal: label})
+			pattern.literals++
+		default:
+			return nil, &RouteError{Fragment: label, Offset: offset, Err: ErrMalformedHost}
+		}
+		offset += len(label) + 1
+	}
+
+	return pattern, nil
+}
+func isHostVariable(name string) bool {
+	for index := 0; index < len(name); index++ {
+		if !isNameCharacter(name[index]) {
+			return false
+		}
+	}
+	return len(name) > 0
+}
+func isHostLiteral(label string) bool {
+	for index := 0; index < len(label); index++ {
+		if character := label[index]; !isLetter(character) && !isDigit(character) && character != '-' && character != '_' {
+			return false
+		}
+	}
+	return len(label) > 0
+}
+
+// canonicalHost returns host in the form hosts are compared in: lowercase, without a port or a trailing dot.
+func canonicalHost(host string) string {
+	if strings.HasPrefix(host, "[") {
+		if end := strings.IndexByte(host, ']'); end > 0 {
+			host = host[:end+1] // an IPv6 literal, whose colons are not a port separator
+		}
+	} else if colon := strings.LastIndexByte(host, ':'); colon >= 0 {
+		host = host[:colon]
+	}
+	return strings.ToLower(strings.TrimSuffix(host, "."))
+}
+
+// match reports whether host, which must already be canonical, matches the pattern, appending the values of its
+// variable labels to params in left-to-right order. Labels are compared from the right, so a wildcard is reached last.
+func (this *hostPattern) match(host string, params Params) (Params, bool) {
+	first := len(params)
+	remaining, more := host, true
+	for index := len(this.labels) - 1; index >= 0; index-- {
+		label := this.labels[index]
+		if !more {
+			return params[:first], false
+		} else if label.wildcard {
+			return params, len(remaining) > 0 && remaining[0] != '.' && remaining[len(remaining)-1] != '.'
+		}
+
+		var value string
+		if dot := strings.LastIndexByte(remaining, '.'); dot >= 0 {
+			value, remaining = remaining[dot+1:], remaining[:dot]
+		} else {
+			value, remaining, more = remaining, "", false
+		}
+
+		if len(value) == 0 || (len(label.name) == 0 && value != label.literal) {
+			return params[:first], false
+		} else if len(label.name) > 0 {
+			params = append(params, Param{Name: label.name, Value: value, Raw: value})
+		}
+	}
+
+	for left, right := first, len(params)-1; left < right; left, right = left+1, right-1 {
+		params[left], params[right] = params[right], params[left]
+	}
+	return params, !more
+}
+
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+
+// hostTable selects the routes serving a request by its host. An exact host is found by lookup; otherwise the
+// patterns are tried in order of precedence: those with variable labels before those with a wildcard, and those with
+// more literal labels first, then in registration order. A request whose host matches no route's Host is served
+// by the routes that have none.
+type hostTable struct {
+	exact    map[string]routeResolver
+	patterns []virtualHost
+}
+type virtualHost struct {
+	pattern  *hostPattern
+	resolver routeResolver
+}
+
+func newHostTable() *hostTable {
+	return &hostTable{exact: make(map[string]routeResolver)}
+}
+func (this *hostTable) Add(pattern *hostPattern, resolver routeResolver) {
+	if pattern.exact {
+		this.exact[pattern.source] = resolver
+		return
+	}
+
+	this.patterns = append(this.patterns, virtualHost{pattern: pattern, resolver: resolver})
+	sort.SliceStable(this.patterns, func(i, j int) bool {
+		left, right := this.patterns[i].pattern, this.patterns[j].pattern
+		if leftWildcard, rightWildcard := left.labels[0].wildcard, right.labels[0].wildcard; leftWildcard != rightWildcard {
+			return rightWildcard
+		}
+		return left.literals > right.literals
+	})
+}
+func (this *hostTable) Select(host string) (routeResolver, Params, bool) {
+	host = canonicalHost(host)
+	if resolver, found := this.exact[host]; found {
+		return resolver, nil, true
+	}
+
+	for _, virtual := range this.patterns {
+		if params, matched := virtual.pattern.match(host, nil); matched {
+			return virtual.resolver, params, true
+		}
+	}
+
+	return nil, nil, false
+}
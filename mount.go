@@ -0,0 +1,112 @@
+package httprouter
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// mount is a handler registered with Options.Mount, which New turns into routes once the router's own handlers for
+// unmatched requests are known.
+type mount struct {
+	prefix  string
+	handler http.Handler
+}
+
+// routes returns the routes that serve the mount: any method at the prefix itself and at every path beneath it.
+func (this mount) routes(notFound, methodNotAllowed http.Handler) []Route {
+	prefix := strings.TrimSuffix(this.prefix, "/")
+	var beneath, bare http.Handler
+	if this.handler != nil {
+		beneath = &mountHandler{prefix: prefix, handler: this.handler, notFound: notFound, methodNotAllowed: methodNotAllowed}
+		bare = &mountHandler{prefix: prefix, bare: true, handler: this.handler, notFound: notFound, methodNotAllowed: methodNotAllowed}
+	}
+
+	routes := []Route{{AllowedMethods: MethodAny, Path: prefix + "/*", Handler: beneath}}
+	if len(prefix) > 0 {
+		routes = append(routes, Route{AllowedMethods: MethodAny, Path: prefix, Handler: bare})
+	}
+	return routes
+}
+
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+
+// mountHandler strips the prefix a mounted handler was registered under from the request before passing it on, so
+// the handler sees paths relative to its mount point. The prefix actually matched (with any variables filled in) is
+// recorded in the request context, after that of any mount enclosing this one.
+type mountHandler struct {
+	prefix           string
+	bare             bool // serves the prefix itself rather than the paths beneath it
+	handler          http.Handler
+	notFound         http.Handler // those of the router the handler is mounted in, which a mounted router defers to
+	methodNotAllowed http.Handler
+}
+
+func (this *mountHandler) ServeHTTP(response http.ResponseWriter, request *http.Request) {
+	prefix, remainder := this.matched(request)
+	state := &mountContext{prefix: prefix, notFound: this.notFound, methodNotAllowed: this.methodNotAllowed}
+	if outer, ok := request.Context().Value(mountContextKey{}).(*mountContext); ok {
+		state.prefix = outer.prefix + prefix
+		state.notFound = inherit(state.notFound, outer.notFound, http.StatusNotFound)
+		state.methodNotAllowed = inherit(state.methodNotAllowed, outer.methodNotAllowed, http.StatusMethodNotAllowed)
+	}
+
+	this.handler.ServeHTTP(response, stripPrefix(request, "/"+remainder, state))
+}
+
+// matched returns the prefix and the remainder (still percent-encoded) of the path this mount was routed on. A
+// prefix without variables is matched as registered; otherwise the route has captured values, so the request carries
+// the context bound for this very match (see endpoint.bind), whose path and wildcard value give both.
+func (this *mountHandler) matched(request *http.Request) (prefix, remainder string) {
+	state, ok := request.Context().Value(routeContextKey{}).(*routeContext)
+	if !ok || len(state.params) == 0 {
+		return this.prefix, ""
+	} else if this.bare {
+		return state.path, ""
+	}
+
+	remainder = state.params[len(state.params)-1].Raw
+	return strings.TrimSuffix(strings.TrimSuffix(state.path, remainder), "/"), remainder
+}
+
+// stripPrefix returns a shallow copy of request (as http.StripPrefix makes) whose URL.Path, URL.RawPath and
+// RequestURI give path, which is percent-encoded, in place of the full path, with state added to its context.
+func stripPrefix(request *http.Request, path string, state *mountContext) *http.Request {
+	stripped := request.WithContext(context.WithValue(request.Context(), mountContextKey{}, state))
+	location := *request.URL
+	stripped.URL = &location
+
+	location.Path, location.RawPath = path, ""
+	if decoded, err := url.PathUnescape(path); err == nil {
+		if location.Path = decoded; location.EscapedPath() != path {
+			location.RawPath = path // as net/url keeps it, only where the decoded path alone can't reproduce it
+		}
+	}
+
+	if len(request.RequestURI) > 0 {
+		stripped.RequestURI = path
+		if index := strings.IndexByte(request.RequestURI, '?'); index >= 0 {
+			stripped.RequestURI += request.RequestURI[index:]
+		}
+	}
+	return stripped
+}
+
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+
+type mountContextKey struct{}
+type mountContext struct {
+	prefix           string
+	notFound         http.Handler
+	methodNotAllowed http.Handler
+}
+
+// inherit returns the handler a router mounted in another should use for an unmatched request: its own, unless that
+// was left at the default status answer and the router it is mounted in has one to offer.
+func inherit(own, inherited http.Handler, status int) http.Handler {
+	if inherited != nil && own == statusHandler(status) {
+		return inherited
+	}
+	return own
+}
@@ -0,0 +1,145 @@
+package httprouter
+
+import "strings"
+
+// pathNormalizer holds, for each policy, the irregularities it applies to. An irregularity in neither set is left in
+// the path as received.
+type pathNormalizer struct {
+	route    PathIrregularity
+	redirect PathIrregularity
+}
+
+func (this *pathNormalizer) set(irregularities PathIrregularity, policy NormalizationPolicy) {
+	this.route &^= irregularities
+	this.redirect &^= irregularities
+
+	switch policy {
+	case NormalizeRoute:
+		this.route |= irregularities
+	case NormalizeRedirect:
+		this.redirect |= irregularities
+	}
+}
+func (this pathNormalizer) enabled() bool { return this.route|this.redirect != 0 }
+
+// Normalize returns the canonical form of path and which of the enabled irregularities it had; found is zero (and
+// path is returned as is, without allocating) when there was nothing to correct.
+func (this pathNormalizer) Normalize(path string) (normalized string, found PathIrregularity) {
+	if !mayBeIrregular(path) || len(path) == 0 || path[0] != '/' {
+		return path, 0
+	}
+
+	enabled := this.route | this.redirect
+	segments := make([]string, 0, strings.Count(path, "/"))
+	for remaining, more := path[1:], true; more; {
+		var segment string
+		segment, remaining, more = strings.Cut(remaining, "/")
+
+		if len(segment) == 0 && more && enabled&PathDuplicateSlashes != 0 {
+			found |= PathDuplicateSlashes
+			continue
+		}
+
+		if dots := dotSegment(segment); dots > 0 && enabled&PathDotSegments != 0 {
+			found |= PathDotSegments
+			if dots == 2 && len(segments) > 0 {
+				segments = segments[:len(segments)-1]
+			}
+			if !more {
+				segments = append(segments, "") // "/a/b/.." addresses the directory "/a/", slash included
+			}
+			continue
+		}
+
+		if enabled&PathTrailingDots != 0 {
+			if trimmed := trimTrailingDots(segment); len(trimmed) < len(segment) {
+				found |= PathTrailingDots
+				segment = trimmed
+			}
+		}
+
+		segments = append(segments, segment)
+	}
+
+	if found == 0 {
+		return path, 0
+	}
+	return "/" + strings.Join(segments, "/"), found
+}
+
+// mayBeIrregular is a cheap scan that rules out the common case of a path with nothing to normalize: no "//", no
+// segment ending in a dot, and no percent-encoded dot.
+func mayBeIrregular(path string) bool {
+	for index := 0; index < len(path); index++ {
+		switch path[index] {
+		case '/':
+			if index+1 < len(path) && path[index+1] == '/' {
+				return true
+			}
+		case '.':
+			if index+1 == len(path) || path[index+1] == '/' {
+				return true
+			}
+		case '%':
+			if isEncodedDot(path[index:]) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// dotSegment returns 1 for a "." segment, 2 for a ".." segment (either spelled with percent-encoded dots), and 0 for
+// anything else.
+func dotSegment(segment string) int {
+	dots := 0
+	for len(segment) > 0 && dots <= 2 {
+		if segment[0] == '.' {
+			segment = segment[1:]
+		} else if isEncodedDot(segment) {
+			segment = segment[3:]
+		} else {
+			return 0
+		}
+		dots++
+	}
+	if dots > 2 {
+		return 0
+	}
+	return dots
+}
+func trimTrailingDots(segment string) string {
+	trimmed := segment
+	for {
+		if strings.HasSuffix(trimmed, ".") {
+			trimmed = trimmed[:len(trimmed)-1]
+		} else if length := len(trimmed); length >= 3 && isEncodedDot(trimmed[length-3:]) {
+			trimmed = trimmed[:length-3]
+		} else {
+			break
+		}
+	}
+
+	if len(trimmed) == 0 {
+		return segment // nothing but dots: a name, not a name with dots trailing
+	}
+	return trimmed
+}
+
+// hidesDotSegment reports whether a segment of path holds a "." or ".." segment between encoded slashes ("%2F", in
+// either case), which decoding the segment would reveal: "..%2F..%2Fetc" is a single segment, but a value captured
+// from it decodes to "../../etc".
+func hidesDotSegment(path string) bool {
+	for _, segment := range strings.Split(path, "/") {
+		parts := strings.Split(strings.ReplaceAll(segment, "%2f", encodedSlash), encodedSlash)
+		for index := 0; len(parts) > 1 && index < len(parts); index++ {
+			if dotSegment(parts[index]) > 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
+func isEncodedDot(value string) bool {
+	return len(value) >= 3 && value[0] == '%' && value[1] == '2' && (value[2] == 'e' || value[2] == 'E')
+}
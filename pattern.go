@@ -0,0 +1,321 @@
+package httprouter
+
+import "strings"
+
+// pathTemplate is the parsed, validated form of a route's path. Registration walks its segments to place the route
+// in the tree, and the resulting endpoint keeps it so the values of variable and wildcard segments can be read back
+// positionally from a path the tree has already matched — the tree itself never records what it consumed.
+type pathTemplate struct {
+	segments []templateSegment
+	captures int // the number of values captured by variables and the wildcard; zero means there is nothing to extract
+}
+type templateSegment struct {
+	kind    segmentKind
+	text    string          // the literal for a static segment (empty for a trailing slash), the fragment as written otherwise
+	name    string          // the name of a plain ":name" variable
+	matcher *segmentMatcher // set for any other variable segment; nil for a plain ":name", which accepts any non-empty segment
+}
+type segmentKind uint8
+
+const (
+	segmentStatic segmentKind = iota
+	segmentVariable
+	segmentWildcard
+)
+
+// parsePathTemplate validates a route path left to right, one '/'-delimited fragment at a time, so that the first
+// problem encountered determines the error returned: a *RouteError locating the fragment at fault, which the caller
+// completes with the route. An empty path addresses the node it is added to; "/" is the empty (trailing-slash)
+// fragment beneath it. Literal text is kept in canonical percent-encoding (see encoding.go), so "/caf%c3%a9" and
+// "/café" register the same path.
+func parsePathTemplate(path string) (template pathTemplate, err error) {
+	if len(path) == 0 {
+		return template, nil
+	}
+	if path[0] != '/' {
+		fragment, _, _ := strings.Cut(path, "/")
+		return template, &RouteError{Fragment: fragment, Offset: 0, Err: ErrMalformedPath}
+	}
+
+	for offset, remaining, more := 1, path[1:], true; more; {
+		var fragment string
+		fragment, remaining, more = strings.Cut(remaining, "/")
+		rejected := func(err error) error { return &RouteError{Fragment: fragment, Offset: offset, Err: err} }
+
+		if len(fragment) == 0 && more {
+			return template, rejected(ErrMalformedPath) // the URL provided looks something like this: /path/to//document (note the double slash)
+		} else if strings.IndexByte(fragment, ':') >= 0 {
+			segment, err := parseVariableSegment(fragment)
+			if err != nil {
+				return template, rejected(err)
+			}
+			template.segments = append(template.segments, segment)
+			template.captures += segment.captures()
+		} else if strings.HasPrefix(fragment, "*") {
+			if _, valid := normalizeLiteral(fragment[1:]); !valid {
+				return template, rejected(ErrInvalidCharacters)
+			} else if more || len(fragment) > 1 {
+				return template, rejected(ErrInvalidWildcard) // must only be "*" and must be the final fragment
+			}
+			template.segments = append(template.segments, templateSegment{kind: segmentWildcard, text: fragment})
+			template.captures++
+		} else if literal, valid := normalizeLiteral(fragment); !valid {
+			return template, rejected(ErrInvalidCharacters)
+		} else {
+			template.segments = append(template.segments, templateSegment{kind: segmentStatic, text: literal})
+		}
+		offset += len(fragment) + 1
+	}
+
+	return template, nil
+}
+
+// parseVariableSegment parses a fragment holding at least one variable. A plain ":name" takes the whole segment; a
+// variable may be followed by a constraint in braces (":id{int}") and may be surrounded by literal text
+// ("report-:year.csv", ":name.:ext", "v:major"). A name is made of letters, digits, '-' and '_', and never ends in
+// '-', so "report-:year-:month" names "year" and "month". Two variables must be separated by a literal, as nothing
+// would otherwise decide where the first one ends.
+func parseVariableSegment(fragment string) (segment templateSegment, err error) {
+	segment = templateSegment{kind: segmentVariable, text: fragment}
+	matcher := &segmentMatcher{}
+
+	for remaining := fragment; len(remaining) > 0; {
+		if remaining[0] != ':' {
+			text, _, _ := strings.Cut(remaining, ":")
+			literal, valid := normalizeLiteral(text)
+			if !valid {
+				return segment, ErrInvalidCharacters
+			}
+			matcher.parts = append(matcher.parts, segmentPart{literal: literal})
+			matcher.literals += len(literal)
+			remaining = remaining[len(text):]
+			continue
+		}
+
+		nameLength := 1
+		for nameLength < len(remaining) && isNameCharacter(remaining[nameLength]) {
+			nameLength++
+		}
+		for nameLength > 1 && remaining[nameLength-1] == '-' {
+			nameLength--
+		}
+		if count := len(matcher.parts); count > 0 && matcher.parts[count-1].variable {
+			return segment, ErrMalformedPath // adjacent variables
+		}
+		part := segmentPart{variable: true, name: remaining[1:nameLength]}
+		remaining = remaining[nameLength:]
+
+		if strings.HasPrefix(remaining, "{") {
+			source, rest, ok := cutConstraint(remaining)
+			if !ok {
+				return segment, ErrInvalidConstraint
+			}
+			if part.constraint, err = parseConstraint(source); err != nil {
+				return segment, err
+			}
+			remaining = rest
+		}
+		matcher.parts = append(matcher.parts, part)
+	}
+
+	if len(matcher.parts) == 1 && matcher.parts[0].constraint == nil {
+		segment.name = matcher.parts[0].name // a plain ":name" needs no matcher at all
+		return segment, nil
+	}
+	matcher.key = matcher.identity()
+	segment.matcher = matcher
+	return segment, nil
+}
+func (this templateSegment) captures() (count int) {
+	if this.matcher == nil {
+		return 1
+	}
+	for _, part := range this.matcher.parts {
+		if part.variable {
+			count++
+		}
+	}
+	return count
+}
+func isNameCharacter(value byte) bool {
+	return isLetter(value) || isDigit(value) || value == '-' || value == '_'
+}
+
+// params reads the value of each variable and wildcard segment out of path, which must be a path the tree resolved
+// to this template: a variable takes its segment (or its share of it, split exactly as the tree split it), and the
+// wildcard takes everything after the final slash it follows, including any further slashes.
+func (this pathTemplate) params(path string) Params {
+	if this.captures == 0 {
+		return nil
+	}
+
+	params := make(Params, 0, this.captures)
+	remaining := strings.TrimPrefix(path, "/")
+	for _, segment := range this.segments {
+		if segment.kind == segmentWildcard {
+			params = append(params, Param{Name: WildcardParam, Value: remaining})
+			break
+		}
+
+		var value string
+		value, remaining, _ = strings.Cut(remaining, "/")
+		if segment.kind != segmentVariable {
+			continue
+		} else if segment.matcher == nil {
+			params = append(params, Param{Name: segment.name, Value: value})
+		} else {
+			params = segment.matcher.capture(value, params)
+		}
+	}
+
+	return params
+}
+
+// canonical returns path, which must be a path the tree resolved to this template ignoring case, with every static
+// segment and every literal within a variable segment spelled as registered. Captured values keep their case.
+func (this pathTemplate) canonical(path string) string {
+	if len(this.segments) == 0 {
+		return path
+	}
+
+	var builder strings.Builder
+	builder.Grow(len(path))
+	remaining := strings.TrimPrefix(path, "/")
+	for _, segment := range this.segments {
+		builder.WriteByte('/')
+		if segment.kind == segmentWildcard {
+			builder.WriteString(remaining)
+			break
+		}
+
+		var value string
+		value, remaining, _ = strings.Cut(remaining, "/")
+		if segment.kind == segmentStatic {
+			builder.WriteString(segment.text)
+		} else if segment.matcher == nil {
+			builder.WriteString(value)
+		} else {
+			segment.matcher.canonical(value, &builder)
+		}
+	}
+	return builder.String()
+}
+
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+
+// segmentMatcher matches a single path segment against a sequence of literal and variable parts. Every variable
+// must take at least one character and satisfy its constraint; where a variable is followed by a literal that occurs
+// more than once, the variable takes as much as it can (so ":name.:ext" splits "jquery.min.js" into "jquery.min" and
+// "js") and gives characters back only if the rest of the segment would otherwise fail to match.
+type segmentMatcher struct {
+	parts    []segmentPart
+	literals int    // the total length of the literal parts; more literal text means a more specific segment
+	key      string // the parts with variable names elided: segments with equal keys accept exactly the same input
+}
+type segmentPart struct {
+	variable   bool
+	literal    string
+	name       string
+	constraint *constraint
+}
+
+func (this *segmentMatcher) identity() string {
+	var builder strings.Builder
+	for _, part := range this.parts {
+		if !part.variable {
+			builder.WriteString(part.literal)
+		} else if part.constraint == nil {
+			builder.WriteString(":")
+		} else {
+			builder.WriteString(":{" + part.constraint.source + "}")
+		}
+	}
+	return builder.String()
+}
+
+// outranks reports whether segments that both matchers accept should be tried against this one first: more literal
+// text wins, and a matcher of any kind outranks the plain variable (nil). Equal ranks keep registration order.
+func (this *segmentMatcher) outranks(that *segmentMatcher) bool {
+	if this == nil {
+		return false
+	}
+	return that == nil || this.literals > that.literals
+}
+func (this *segmentMatcher) equals(that *segmentMatcher) bool {
+	if this == nil || that == nil {
+		return this == that
+	}
+	return this.key == that.key
+}
+func (this *segmentMatcher) matches(segment string, fold bool) bool {
+	_, matched := this.match(this.parts, segment, nil, false, fold)
+	return matched
+}
+func (this *segmentMatcher) capture(segment string, params Params) Params {
+	params, _ = this.match(this.parts, segment, params, true, false)
+	return params
+}
+
+// canonical writes segment to builder with its literal text spelled as registered and its variable values as
+// received. The segment must be one the matcher accepts ignoring case.
+func (this *segmentMatcher) canonical(segment string, builder *strings.Builder) {
+	captured, _ := this.match(this.parts, segment, nil, true, true)
+	for _, part := range this.parts {
+		if !part.variable {
+			builder.WriteString(part.literal)
+		} else if len(captured) > 0 {
+			builder.WriteString(captured[0].Value)
+			captured = captured[1:]
+		}
+	}
+}
+
+// match reports whether segment matches parts, appending the variable values to params when capture is set. With
+// fold set, literal text is compared without regard to ASCII case; the values captured are never altered.
+func (this *segmentMatcher) match(parts []segmentPart, segment string, params Params, capture, fold bool) (Params, bool) {
+	if len(parts) == 0 {
+		return params, len(segment) == 0
+	}
+
+	part := parts[0]
+	if !part.variable {
+		if !hasLiteralPrefix(segment, part.literal, fold) {
+			return params, false
+		}
+		return this.match(parts[1:], segment[len(part.literal):], params, capture, fold)
+	}
+
+	if len(parts) == 1 {
+		if len(segment) == 0 || !part.accepts(segment) {
+			return params, false
+		}
+		if capture {
+			params = append(params, Param{Name: part.name, Value: segment})
+		}
+		return params, true
+	}
+
+	next := parts[1].literal // variables are always separated by a literal
+	for end := len(segment) - len(next); end > 0; end-- {
+		if !hasLiteralPrefix(segment[end:], next, fold) || !part.accepts(segment[:end]) {
+			continue
+		}
+		captured := params
+		if capture {
+			captured = append(params, Param{Name: part.name, Value: segment[:end]})
+		}
+		if captured, matched := this.match(parts[1:], segment[end:], captured, capture, fold); matched {
+			return captured, true
+		}
+	}
+	return params, false
+}
+func (this segmentPart) accepts(value string) bool {
+	return this.constraint == nil || this.constraint.match(value)
+}
+func hasLiteralPrefix(segment, literal string, fold bool) bool {
+	if !fold {
+		return strings.HasPrefix(segment, literal)
+	}
+	return len(segment) >= len(literal) && equalFoldASCII(segment[:len(literal)], literal)
+}
@@ -6,34 +6,287 @@ import (
 )
 
 type defaultRouter struct {
-	resolver         routeResolver
-	notFound         http.Handler
-	methodNotAllowed http.Handler
-	monitor          Monitor
+	resolver          routeResolver
+	hosts             *hostTable                      // the routes of each host, if any route has one; resolver serves every other host
+	groups            map[routeResolver][]*groupScope // the group scopes of each resolver with any; see groupScope
+	notFound          http.Handler
+	methodNotAllowed  http.Handler
+	badRequest        http.Handler
+	notImplemented    http.Handler // answers methods that are neither built in nor registered; nil leaves them to 404/405
+	automaticOptions  bool
+	serverMethods     Method // every method some route allows, which answers "OPTIONS *"
+	versioning        VersionSelector
+	defaultVersion    string
+	notAcceptable     http.Handler
+	predicateMismatch http.Handler // answers a request no candidate's predicates hold for; nil answers as not found
+	monitor           Monitor
+	routeMonitor      RouteMonitor     // the monitor again, if it implements the extension; otherwise nil
+	rejectionMonitor  RejectionMonitor // likewise
+	trailingSlash     TrailingSlashPolicy
+	caseMatching      CaseMatchingPolicy
+	encodedSlash      EncodedSlashPolicy
+	normalizer        pathNormalizer
 }
 
-func newRouter(resolver routeResolver, notFound, methodNotAllowed http.Handler, monitor Monitor) http.Handler {
-	return &defaultRouter{resolver: resolver, notFound: notFound, methodNotAllowed: methodNotAllowed, monitor: monitor}
+func newRouter(resolver routeResolver, notFound, methodNotAllowed http.Handler, monitor Monitor) *defaultRouter {
+	routeMonitor, _ := monitor.(RouteMonitor)
+	rejectionMonitor, _ := monitor.(RejectionMonitor)
+	return &defaultRouter{resolver: resolver, notFound: notFound, methodNotAllowed: methodNotAllowed, monitor: monitor,
+		routeMonitor: routeMonitor, rejectionMonitor: rejectionMonitor}
 }
 func (this *defaultRouter) ServeHTTP(response http.ResponseWriter, request *http.Request) {
-	rawPath := request.RequestURI
-	if len(rawPath) == 0 {
-		rawPath = request.URL.Path
-	} else if index := strings.IndexByte(rawPath, '?'); index >= 0 {
-		rawPath = rawPath[0:index]
+	rawPath := requestPath(request)
+	if rawPath == "*" && request.Method == http.MethodOptions && this.automaticOptions {
+		this.monitor.Routed(request)
+		answerOptions(response, this.serverMethods)
+		return
+	}
+
+	if mayNeedEncoding(rawPath) {
+		if canonical, valid := normalizeEncoding(rawPath); valid {
+			rawPath = canonical
+		}
+	}
+	if this.encodedSlash == EncodedSlashDecodeValues && strings.IndexByte(rawPath, '%') >= 0 && hidesDotSegment(rawPath) {
+		this.rejected(request, http.StatusBadRequest)
+		this.badRequest.ServeHTTP(response, request) // the values decoded would climb out of the directory they name
+		return
+	}
+	if this.encodedSlash >= EncodedSlashDecodePath && strings.Contains(rawPath, encodedSlash) {
+		if this.encodedSlash == EncodedSlashReject {
+			this.rejected(request, http.StatusBadRequest)
+			this.badRequest.ServeHTTP(response, request)
+			return
+		}
+		rawPath = strings.ReplaceAll(rawPath, encodedSlash, "/")
+	}
+
+	if this.normalizer.enabled() {
+		if normalized, found := this.normalizer.Normalize(rawPath); found&this.normalizer.redirect != 0 {
+			redirect(response, request, normalized)
+			return
+		} else if found != 0 {
+			rawPath = normalized
+		}
+	}
+
+	resolver, hostParams := this.resolver, Params(nil)
+	if this.hosts != nil {
+		if selected, params, found := this.hosts.Select(request.Host); found {
+			resolver, hostParams = selected, params
+		}
+	}
+
+	endpoint, allowed, resolvedPath, folded := this.resolve(resolver, request.Method, rawPath)
+	if endpoint == nil && allowed == 0 && this.trailingSlash != TrailingSlashStrict {
+		alternate, alternateAllowed, alternatePath, alternateFolded := this.resolve(resolver, request.Method, toggleTrailingSlash(rawPath))
+		if this.trailingSlash == TrailingSlashTolerant {
+			endpoint, allowed, resolvedPath, folded = alternate, alternateAllowed, alternatePath, alternateFolded
+		} else if alternate != nil {
+			redirect(response, request, alternatePath)
+			return
+		} else {
+			allowed = alternateAllowed // a 405 listing what the other spelling allows, as for a case-folded spelling
+		}
+	}
+	if folded && this.caseMatching == CaseInsensitiveRedirect {
+		redirect(response, request, resolvedPath)
+		return
+	}
+	rawPath = resolvedPath
+
+	if endpoint != nil && endpoint.conditional() {
+		if endpoint = this.selectCandidate(endpoint, resolver, rawPath, response, request); endpoint == nil {
+			return
+		}
 	}
 
-	handler, allowed := this.resolver.Resolve(request.Method, rawPath)
-	if handler != nil {
+	if endpoint != nil {
+		request = endpoint.bind(request, rawPath, this.encodedSlash != EncodedSlashPreserve, hostParams)
+		this.monitor.Routed(request)
+		if this.routeMonitor != nil {
+			this.routeMonitor.RoutedTo(request, endpoint.route)
+		}
+		endpoint.handler.ServeHTTP(response, request)
+	} else if this.notImplemented != nil && !isKnownMethod(request.Method) {
+		this.rejected(request, http.StatusNotImplemented)
+		this.notImplemented.ServeHTTP(response, request)
+	} else if allowed > 0 && request.Method == http.MethodOptions && this.automaticOptions {
 		this.monitor.Routed(request)
-		handler.ServeHTTP(response, request)
+		answerOptions(response, allowed)
 	} else if allowed > 0 {
 		this.monitor.MethodNotAllowed(request)
+		if this.automaticOptions {
+			allowed |= MethodOptions // which the path is answered for, as the OPTIONS response itself advertises
+		}
 		response.Header().Set("Allow", allowed.HeaderValue())
-		this.methodNotAllowed.ServeHTTP(response, request)
+		this.fallback(request, resolver, rawPath, http.StatusMethodNotAllowed).ServeHTTP(response, request)
 	} else {
 		this.monitor.NotFound(request)
-		this.notFound.ServeHTTP(response, request)
+		this.fallback(request, resolver, rawPath, http.StatusNotFound).ServeHTTP(response, request)
+	}
+}
+
+// rejected reports a request the router answers itself with status to the monitor, if it implements RejectionMonitor.
+func (this *defaultRouter) rejected(request *http.Request, status int) {
+	if this.rejectionMonitor != nil {
+		this.rejectionMonitor.Rejected(request, status)
+	}
+}
+
+// fallback returns the handler that answers with status (404 or 405) a request for path that no route of resolver
+// serves: that of the innermost group the path lies beneath (see Group.NotFound), or else this router's own, or if
+// this router is mounted in another (see Options.Mount) and its own is the default, that router's handler instead.
+func (this *defaultRouter) fallback(request *http.Request, resolver routeResolver, path string, status int) http.Handler {
+	own := this.notFound
+	if status == http.StatusMethodNotAllowed {
+		own = this.methodNotAllowed
+	}
+	if scopes := this.groups[resolver]; len(scopes) > 0 {
+		if handler := innermostScope(scopes, path, this.caseMatching != CaseSensitive).handler(status); handler != nil {
+			return handler
+		}
+	}
+
+	mount, ok := request.Context().Value(mountContextKey{}).(*mountContext)
+	if !ok {
+		return own
+	} else if status == http.StatusNotFound {
+		return inherit(own, mount.notFound, status)
+	}
+	return inherit(own, mount.methodNotAllowed, status)
+}
+
+// selectCandidate returns the endpoint in the chain that serves the request, or answers the request itself and returns
+// nil if none does: the first candidate whose predicates hold, unless that one is versioned, in which case the version
+// asked for decides among every versioned candidate whose predicates hold.
+func (this *defaultRouter) selectCandidate(endpoint *endpoint, resolver routeResolver, path string, response http.ResponseWriter, request *http.Request) *endpoint {
+	if endpoint = endpoint.selectPredicates(request); endpoint == nil {
+		this.monitor.NotFound(request) // no route serves the request, whoever answers it
+		if this.predicateMismatch != nil {
+			this.predicateMismatch.ServeHTTP(response, request)
+		} else {
+			this.fallback(request, resolver, path, http.StatusNotFound).ServeHTTP(response, request)
+		}
+		return nil
+	} else if !endpoint.versioned() {
+		return endpoint
+	}
+
+	if vary := this.versioning.vary(); len(vary) > 0 {
+		response.Header().Add("Vary", vary)
+	}
+
+	if selected := endpoint.selectVersion(request, this.versioning.requested(request), this.defaultVersion); selected != nil {
+		return selected
+	} else if this.versioning.source == versionFromAccept {
+		this.rejected(request, http.StatusNotAcceptable)
+		this.notAcceptable.ServeHTTP(response, request)
+	} else {
+		this.rejected(request, http.StatusBadRequest)
+		this.badRequest.ServeHTTP(response, request)
+	}
+	return nil
+}
+
+// requestPath returns the path of the request as the client sent it, still percent-encoded, or the decoded path if
+// the request didn't come from a client.
+func requestPath(request *http.Request) string {
+	path := request.RequestURI
+	if len(path) == 0 {
+		return request.URL.Path
+	} else if index := strings.IndexByte(path, '?'); index >= 0 {
+		return path[0:index]
+	}
+	return path
+}
+
+// answerOptions responds to an OPTIONS request that has no route of its own, listing the methods allowed (OPTIONS
+// included) without a body.
+func answerOptions(response http.ResponseWriter, allowed Method) {
+	response.Header().Set("Allow", (allowed | MethodOptions).HeaderValue())
+	response.WriteHeader(http.StatusNoContent)
+}
+func isKnownMethod(method string) bool {
+	_, known := methodIndex(method)
+	return known
+}
+
+const encodedSlash = "%2F" // the only spelling left once the path is canonically encoded
+
+// resolve resolves path exactly and, if that finds nothing and the case-matching policy allows, again ignoring case.
+// A route found only by ignoring case is reported as folded along with the path spelled as it was registered, which
+// is also the path its values are then read from.
+func (this *defaultRouter) resolve(resolver routeResolver, method, path string) (*endpoint, Method, string, bool) {
+	endpoint, allowed := resolver.Resolve(method, path)
+	if endpoint != nil || allowed != 0 || this.caseMatching == CaseSensitive {
+		return endpoint, allowed, path, false
+	}
+
+	if endpoint, allowed = resolver.ResolveFold(method, path); endpoint == nil {
+		return nil, allowed, path, false
+	}
+	canonical := endpoint.template.canonical(path)
+	return endpoint, allowed, canonical, canonical != path
+}
+
+// toggleTrailingSlash returns the other spelling of path: without its trailing slash if it has one, with one if not.
+// The root path has no other spelling.
+func toggleTrailingSlash(path string) string {
+	if len(path) <= 1 {
+		return path
+	} else if path[len(path)-1] == '/' {
+		return path[:len(path)-1]
+	}
+	return path + "/"
+}
+
+// redirect sends the client to path, keeping the query string. GET and HEAD are sent a 301 so caches and crawlers
+// learn the canonical location; every other method gets a 308, which (unlike a 301) obliges the client to repeat the
+// same method with the same body. A router mounted in another (see Options.Mount) sees paths relative to its mount
+// point, so the prefix stripped on the way in is put back.
+func redirect(response http.ResponseWriter, request *http.Request, path string) {
+	if strings.HasPrefix(path, "//") {
+		path = "/" + strings.TrimLeft(path, "/") // never a scheme-relative URL, which would leave this host
+	}
+	path = MountPrefixFromContext(request.Context()) + path
+	if request.URL != nil && len(request.URL.RawQuery) > 0 {
+		path += "?" + request.URL.RawQuery
+	}
+
+	status := http.StatusPermanentRedirect
+	if request.Method == http.MethodGet || request.Method == http.MethodHead {
+		status = http.StatusMovedPermanently
+	}
+
+	response.Header().Set("Location", path)
+	response.WriteHeader(status)
+}
+
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+
+// builtRouter is the Router that New returns: the defaultRouter, wrapped by any pre-routing middleware and recovery,
+// together with the configuration (routes included) it was built from and the trees built from that.
+type builtRouter struct {
+	http.Handler
+	config configuration
+	trees  []routeTree
+	names  namedRoutes
+}
+
+func (this *builtRouter) URL(name string, params ...string) (string, error) {
+	return this.names.URL(name, params)
+}
+func (this *builtRouter) Routes() []Route {
+	return append([]Route(nil), this.config.Routes...)
+}
+func (this *builtRouter) Walk(visit func(Route, NodeInfo)) {
+	for _, tree := range this.trees {
+		var host string
+		if tree.pattern != nil {
+			host = tree.pattern.source
+		}
+		tree.root.walk(NodeInfo{Host: host}, visit)
 	}
 }
 
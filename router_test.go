@@ -1,12 +1,18 @@
 package httprouter
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"maps"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestRouting(t *testing.T) {
@@ -49,7 +55,7 @@ func TestRouting(t *testing.T) {
 	assertRoute(t, router, "OPTIONS", "/test1/path/to/document ", 405, "Method Not Allowed\n", "GET, HEAD, POST, DELETE, PATCH")
 	assertRoute(t, router, "DELETE ", "/test1/path/to/document ", 200, "3", "")
 	assertRoute(t, router, "PATCH  ", "/test1/path/to/document ", 200, "18", "")
-	assertRoute(t, router, "BOGUS  ", "/test1/path/to/document ", 405, "Method Not Allowed\n", "GET, HEAD, POST, DELETE, PATCH")
+	assertRoute(t, router, "BOGUS  ", "/test1/path/to/document ", 501, "Not Implemented\n", "")
 
 	assertRoute(t, router, "GET    ", "/test2/path/to/document               ", 200, "4", "")
 	assertRoute(t, router, "PUT    ", "/test2/path/to/document               ", 200, "5", "")
@@ -221,6 +227,1201 @@ func TestCompactionCollapsesNodes(t *testing.T) {
 	Assert(t).That(merged.static[0].pathFragment).Equals("z")
 }
 
+func TestCapturedValues(t *testing.T) {
+	router := RequireNew(
+		Options.Routes(
+			ParseRoute("GET", "/users/:id", paramsHandler{"id"}),
+			ParseRoute("GET", "/users/:id/orders/:order", paramsHandler{"id", "order"}),
+			ParseRoute("GET", "/files/:bucket/*", paramsHandler{"bucket", WildcardParam}),
+			ParseRoute("GET", "/static/*", paramsHandler{WildcardParam}),
+			ParseRoute("GET", "/a/b/c/:leaf", paramsHandler{"leaf"}), // compacted static prefix before the variable
+		),
+	)
+
+	assertRoute(t, router, "GET", "/users/42", 200, "id=42", "")
+	assertRoute(t, router, "GET", "/users/42/orders/7", 200, "id=42,order=7", "")
+	assertRoute(t, router, "GET", "/files/images/2024/01/cat.png", 200, "bucket=images,*=2024/01/cat.png", "")
+	assertRoute(t, router, "GET", "/files/images/", 200, "bucket=images,*=", "")
+	assertRoute(t, router, "GET", "/static/css/app.css", 200, "*=css/app.css", "")
+	assertRoute(t, router, "GET", "/a/b/c/d", 200, "leaf=d", "")
+}
+func TestCapturedValuesMatchContext(t *testing.T) {
+	var captured Params
+	router := RequireNew(Options.AddRoute("GET", "/users/:id/*", http.HandlerFunc(func(_ http.ResponseWriter, request *http.Request) {
+		captured = ParamsFromContext(request.Context())
+	})))
+
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/users/42/a/b", nil))
+
+	Assert(t).That(captured).Equals(Params{{Name: "id", Value: "42", Raw: "42"}, {Name: WildcardParam, Value: "a/b", Raw: "a/b"}})
+	Assert(t).That(captured.Get("id")).Equals("42")
+	Assert(t).That(captured.Get("missing")).Equals("")
+}
+func TestStaticRouteAllocations(t *testing.T) {
+	var params Params
+	router := RequireNew(Options.AddRoute("GET", "/users/all", http.HandlerFunc(func(_ http.ResponseWriter, request *http.Request) {
+		params = ParamsFromContext(request.Context())
+	})))
+	request := httptest.NewRequest("GET", "/users/all", nil)
+
+	allocations := testing.AllocsPerRun(100, func() { router.ServeHTTP(nil, request) })
+
+	Assert(t).That(allocations).Equals(1.0) // the request and its context, carrying the route's shared value, together
+	Assert(t).That(params).IsNil()
+}
+
+func TestMatchedPattern(t *testing.T) {
+	monitor := &recordingMonitor{}
+	router := RequireNew(
+		Options.Routes(
+			ParseRoute("GET|PUT", "/users/:id", patternHandler{}),
+			ParseRoute("GET", "/health", patternHandler{}),
+		),
+		Options.Monitor(monitor),
+	)
+
+	assertRoute(t, router, "GET", "/users/8812", 200, "GET /users/:id", "")
+	assertRoute(t, router, "PUT", "/users/8812", 200, "PUT /users/:id", "")
+	assertRoute(t, router, "GET", "/health", 200, "GET /health", "")
+	assertRoute(t, router, "GET", "/missing", 404, "Not Found\n", "")
+
+	Assert(t).That(monitor.routed).Equals([]string{"GET|PUT /users/:id", "GET|PUT /users/:id", "GET /health"})
+}
+func TestMatchedRouteFromContext(t *testing.T) {
+	var route Route
+	var pattern string
+	var found bool
+	router := RequireNew(Options.AddRoute("GET|HEAD", "/users/:id", http.HandlerFunc(func(_ http.ResponseWriter, request *http.Request) {
+		route, pattern, found = RouteFromContext(request.Context())
+	})))
+
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("HEAD", "/users/42", nil))
+
+	Assert(t).That(found).Equals(true)
+	Assert(t).That(pattern).Equals("HEAD /users/:id")
+	Assert(t).That(route.String()).Equals("GET|HEAD /users/:id")
+
+	type outerKey struct{}
+	var outer any
+	static := RequireNew(Options.AddRoute("GET", "/health", http.HandlerFunc(func(_ http.ResponseWriter, request *http.Request) {
+		route, pattern, found = RouteFromContext(request.Context())
+		outer = request.Context().Value(outerKey{})
+	})))
+	request := httptest.NewRequest("GET", "/health", nil)
+	static.ServeHTTP(httptest.NewRecorder(), request.WithContext(context.WithValue(request.Context(), outerKey{}, "outer")))
+	Assert(t).That(outer).Equals("outer") // the values of the context routed still show through
+	Assert(t).That(found).Equals(true)
+	Assert(t).That(pattern).Equals("GET /health")
+	Assert(t).That(route.String()).Equals("GET /health")
+
+	_, _, found = RouteFromContext(context.Background())
+	Assert(t).That(found).Equals(false)
+}
+
+func TestConstrainedVariables(t *testing.T) {
+	router := RequireNew(
+		Options.Routes(
+			ParseRoute("GET", "/orders/:reference", paramsHandler{"reference"}), // registered first, still tried last
+			ParseRoute("GET", "/orders/:id{int}", paramsHandler{"id"}),
+			ParseRoute("GET", "/orders/:uuid{uuid}", paramsHandler{"uuid"}),
+			ParseRoute("GET", "/tags/:slug{[a-z-]+}", paramsHandler{"slug"}),
+			ParseRoute("GET", "/tags/*", simpleHandler("tags-wildcard")),
+			ParseRoute("GET", "/codes/:code{[A-Z]{2,3}}", paramsHandler{"code"}),
+			ParseRoute("GET", "/items/:id{int}", paramsHandler{"id"}),
+			ParseRoute("DELETE", "/items/:name", paramsHandler{"name"}),
+		),
+	)
+
+	assertRoute(t, router, "GET", "/orders/42", 200, "id=42", "")
+	assertRoute(t, router, "GET", "/orders/-7", 200, "id=-7", "")
+	assertRoute(t, router, "GET", "/orders/8c0a2b4e-5f1d-4c6a-9e3b-2d7f1a0b9c8e", 200, "uuid=8c0a2b4e-5f1d-4c6a-9e3b-2d7f1a0b9c8e", "")
+	assertRoute(t, router, "GET", "/orders/ORD-2024-0001", 200, "reference=ORD-2024-0001", "")
+	assertRoute(t, router, "GET", "/tags/go-routing", 200, "slug=go-routing", "")
+	assertRoute(t, router, "GET", "/tags/Go", 200, "tags-wildcard", "") // fails the constraint, falls through to the wildcard
+	assertRoute(t, router, "GET", "/codes/USA", 200, "code=USA", "")
+	assertRoute(t, router, "GET", "/codes/USAX", 404, "Not Found\n", "") // fails the only constraint, nothing to fall back to
+	assertRoute(t, router, "GET", "/items/12", 200, "id=12", "")
+	assertRoute(t, router, "DELETE", "/items/12", 200, "name=12", "") // method backtracks to the next accepting variable
+	assertRoute(t, router, "PUT", "/items/12", 405, "Method Not Allowed\n", "GET, DELETE")
+	assertRoute(t, router, "PUT", "/items/twelve", 405, "Method Not Allowed\n", "DELETE") // only the accepting variable is allowed
+}
+func TestVariablesShareNodeAcrossNames(t *testing.T) {
+	tree := &treeNode{}
+	_, err1 := addRouteWithError(tree, "GET", "/orders/:id")
+	_, err2 := addRouteWithError(tree, "POST", "/orders/:reference")
+	_, err3 := addRouteWithError(tree, "GET", "/orders/:reference")
+	_, err4 := addRouteWithError(tree, "GET", "/orders/:reference{int}")
+
+	Assert(t).That(err1).IsNil()
+	Assert(t).That(err2).IsNil()
+	Assert(t).That(err3).Wraps(ErrRouteExists)
+	Assert(t).That(err4).IsNil()
+	Assert(t).That(len(tree.static[0].variables)).Equals(2)
+	Assert(t).That(tree.static[0].variables[0].matcher.key).Equals(":{int}")
+}
+func TestMidSegmentVariables(t *testing.T) {
+	router := RequireNew(
+		Options.Routes(
+			ParseRoute("GET", "/reports/report-:year.csv", paramsHandler{"year"}),
+			ParseRoute("GET", "/reports/:name", paramsHandler{"name"}),
+			ParseRoute("GET", "/files/:name.:ext", paramsHandler{"name", "ext"}),
+			ParseRoute("GET", "/files/:name.txt", simpleHandler("text")), // more literal text, tried first
+			ParseRoute("GET", "/files/readme", simpleHandler("readme")),
+			ParseRoute("GET", "/v:major/users", paramsHandler{"major"}),
+			ParseRoute("GET", "/v1/users", simpleHandler("v1")),
+			ParseRoute("GET", "/dates/:year{int}-:month{int}", paramsHandler{"year", "month"}),
+			ParseRoute("GET", "/spans/:from-:to", paramsHandler{"from", "to"}),
+		),
+	)
+
+	assertRoute(t, router, "GET", "/reports/report-2024.csv", 200, "year=2024", "")
+	assertRoute(t, router, "GET", "/reports/summary", 200, "name=summary", "")
+	assertRoute(t, router, "GET", "/reports/report-.csv", 200, "name=report-.csv", "") // a variable takes at least one character
+	assertRoute(t, router, "GET", "/files/jquery.min.js", 200, "name=jquery.min,ext=js", "")
+	assertRoute(t, router, "GET", "/files/notes.txt", 200, "text", "")
+	assertRoute(t, router, "GET", "/files/readme", 200, "readme", "")
+	assertRoute(t, router, "GET", "/files/noext", 404, "Not Found\n", "")
+	assertRoute(t, router, "GET", "/files/.hidden", 404, "Not Found\n", "")
+	assertRoute(t, router, "GET", "/v2/users", 200, "major=2", "")
+	assertRoute(t, router, "GET", "/v1/users", 200, "v1", "")
+	assertRoute(t, router, "GET", "/dates/2024-06", 200, "year=2024,month=06", "")
+	assertRoute(t, router, "GET", "/dates/2024-june", 404, "Not Found\n", "")
+	assertRoute(t, router, "GET", "/spans/mon-fri", 200, "from=mon,to=fri", "")
+}
+func TestMalformedMidSegmentRegistration(t *testing.T) {
+	tree := &treeNode{}
+	_, err1 := addRouteWithError(tree, "GET", "/files/:name:ext")
+	_, err2 := addRouteWithError(tree, "GET", "/files/:name{alpha}:ext")
+	_, err3 := addRouteWithError(tree, "GET", "/files/:name.*")
+	_, err4 := addRouteWithError(tree, "GET", "/files/:name.ext{int}")
+	Assert(t).That(err1).Wraps(ErrMalformedPath)
+	Assert(t).That(err2).Wraps(ErrMalformedPath)
+	Assert(t).That(err3).Wraps(ErrInvalidCharacters)
+	Assert(t).That(err4).Wraps(ErrInvalidCharacters)
+}
+func TestMalformedConstraintRegistration(t *testing.T) {
+	tree := &treeNode{}
+	_, err1 := addRouteWithError(tree, "GET", "/users/:id{int")
+	_, err2 := addRouteWithError(tree, "GET", "/users/:id{}")
+	_, err3 := addRouteWithError(tree, "GET", "/users/:id{[a-z}")
+	_, err4 := addRouteWithError(tree, "GET", "/users/:id{int}*")
+	_, err5 := addRouteWithError(tree, "GET", "/users/:i*d{int}")
+	_, err6 := addRouteWithError(tree, "GET", "/users/:id{a)|(b}") // well formed only once wrapped in an anchored group
+	Assert(t).That(err1).Wraps(ErrInvalidConstraint)
+	Assert(t).That(err2).Wraps(ErrInvalidConstraint)
+	Assert(t).That(err3).Wraps(ErrInvalidConstraint)
+	Assert(t).That(err4).Wraps(ErrInvalidCharacters)
+	Assert(t).That(err5).Wraps(ErrInvalidCharacters)
+	Assert(t).That(err6).Wraps(ErrInvalidConstraint)
+}
+
+func TestTrailingSlashPolicy(t *testing.T) {
+	routes := Options.Routes(
+		ParseRoute("GET|POST", "/users", simpleHandler("users")),
+		ParseRoute("GET", "/groups/", simpleHandler("groups")),
+		ParseRoute("GET", "/users/:id", paramsHandler{"id"}),
+		ParseRoute("GET", "/files/*", paramsHandler{WildcardParam}),
+		ParseRoute("GET", "/both", simpleHandler("without")),
+		ParseRoute("GET", "/both/", simpleHandler("with")),
+	)
+
+	strict := RequireNew(routes)
+	assertRoute(t, strict, "GET", "/users/", 404, "Not Found\n", "")
+	assertRoute(t, strict, "GET", "/groups", 404, "Not Found\n", "")
+	assertRoute(t, strict, "GET", "/files", 404, "Not Found\n", "")
+
+	redirecting := RequireNew(routes, Options.TrailingSlash(TrailingSlashRedirect))
+	assertRedirect(t, redirecting, "GET", "/users/", 301, "/users?query=value")
+	assertRedirect(t, redirecting, "POST", "/users/", 308, "/users?query=value")
+	assertRoute(t, redirecting, "PUT", "/groups", 405, "Method Not Allowed\n", "GET") // as "/groups/" would be
+	assertRoute(t, redirecting, "DELETE", "/users/", 405, "Method Not Allowed\n", "GET, POST")
+	assertRedirect(t, redirecting, "GET", "/groups", 301, "/groups/?query=value")
+	assertRedirect(t, redirecting, "GET", "/users/42/", 301, "/users/42?query=value")
+	assertRedirect(t, redirecting, "GET", "/files", 301, "/files/?query=value")
+	assertRoute(t, redirecting, "GET", "/both/", 200, "with", "") // an exact match always wins
+
+	tolerant := RequireNew(routes, Options.TrailingSlash(TrailingSlashTolerant))
+	assertRoute(t, tolerant, "GET", "/users/", 200, "users", "")
+	assertRoute(t, tolerant, "PUT", "/users/", 405, "Method Not Allowed\n", "GET, POST")
+	assertRoute(t, tolerant, "GET", "/groups", 200, "groups", "")
+	assertRoute(t, tolerant, "GET", "/users/42/", 200, "id=42", "")
+	assertRoute(t, tolerant, "GET", "/files", 200, "*=", "")
+	assertRoute(t, tolerant, "GET", "/both", 200, "without", "")
+	assertRoute(t, tolerant, "GET", "/both/", 200, "with", "")
+	assertRoute(t, tolerant, "GET", "/missing/", 404, "Not Found\n", "")
+}
+func TestPathNormalization(t *testing.T) {
+	routes := Options.Routes(
+		ParseRoute("GET|POST", "/users/:id", paramsHandler{"id"}),
+		ParseRoute("GET", "/users/", simpleHandler("users")),
+		ParseRoute("GET", "/files/*", paramsHandler{WildcardParam}),
+		ParseRoute("GET", "/", simpleHandler("root")),
+	)
+
+	raw := RequireNew(routes)
+	assertRoute(t, raw, "GET", "/users//42", 404, "Not Found\n", "")
+	assertRoute(t, raw, "GET", "/files/../secret", 200, "*=../secret", "")
+
+	routing := RequireNew(routes, Options.NormalizePath(PathDuplicateSlashes|PathDotSegments|PathTrailingDots, NormalizeRoute))
+	assertRoute(t, routing, "GET", "/users//42", 200, "id=42", "")
+	assertRoute(t, routing, "GET", "//users/42", 200, "id=42", "")
+	assertRoute(t, routing, "GET", "/a/../users/./42", 200, "id=42", "")
+	assertRoute(t, routing, "GET", "/users/%2e/42", 200, "id=42", "")
+	assertRoute(t, routing, "GET", "/users/42.", 200, "id=42", "")
+	assertRoute(t, routing, "GET", "/users/42/..", 200, "users", "")
+	assertRoute(t, routing, "GET", "/users/...", 200, "id=...", "") // only dots: a name, not trailing dots
+	assertRoute(t, routing, "GET", "/files/../../../etc/passwd", 404, "Not Found\n", "")
+	assertRoute(t, routing, "GET", "/files/a/../../../files/b", 200, "*=b", "") // never above the root
+	assertRoute(t, routing, "GET", "/files/%2e%2e/%2E./secret", 404, "Not Found\n", "")
+	assertRoute(t, routing, "GET", "/..", 200, "root", "")
+
+	redirecting := RequireNew(routes,
+		Options.NormalizePath(PathDuplicateSlashes|PathDotSegments, NormalizeRedirect),
+		Options.NormalizePath(PathTrailingDots, NormalizeRoute))
+	assertRedirect(t, redirecting, "GET", "/users//42", 301, "/users/42?query=value")
+	assertRedirect(t, redirecting, "POST", "/a/../users/42", 308, "/users/42?query=value")
+	assertRedirect(t, redirecting, "GET", "//users/42.", 301, "/users/42?query=value") // corrects everything at once
+	assertRedirect(t, redirecting, "GET", "/users/42.", 200, "")
+	assertRedirect(t, redirecting, "GET", "/users/42", 200, "")
+
+	partial := RequireNew(routes, Options.NormalizePath(PathDotSegments, NormalizeRoute))
+	assertRoute(t, partial, "GET", "/users/./42", 200, "id=42", "")
+	assertRoute(t, partial, "GET", "/users//42", 404, "Not Found\n", "")
+	assertRoute(t, partial, "GET", "/users/42.", 200, "id=42.", "")
+
+	disabled := RequireNew(routes,
+		Options.NormalizePath(PathDotSegments, NormalizeRedirect),
+		Options.NormalizePath(PathDotSegments, NormalizeNone))
+	assertRoute(t, disabled, "GET", "/files/../secret", 200, "*=../secret", "")
+}
+func TestCaseMatchingPolicy(t *testing.T) {
+	segments := []string{"alpha", "bravo", "charlie", "delta", "echo", "foxtrot", "golf", "hotel", "india"}
+	routes := make([]Route, 0, len(segments)+6)
+	for _, segment := range segments {
+		routes = append(routes, ParseRoute("GET", "/wide/"+segment, simpleHandler(segment))) // indexed wide node
+	}
+	routes = append(routes,
+		ParseRoute("GET|POST", "/api/v1/Users/:id", paramsHandler{"id"}), // compacted into one fragment
+		ParseRoute("GET", "/reports/report-:year{int}.csv", paramsHandler{"year"}),
+		ParseRoute("GET", "/files/*", paramsHandler{WildcardParam}),
+		ParseRoute("GET", "/exact", simpleHandler("lower")),
+		ParseRoute("GET", "/EXACT", simpleHandler("upper")),
+	)
+	options := Options.Routes(routes...)
+
+	sensitive := RequireNew(options)
+	assertRoute(t, sensitive, "GET", "/api/v1/users/42", 404, "Not Found\n", "")
+	assertRoute(t, sensitive, "GET", "/WIDE/Golf", 404, "Not Found\n", "")
+
+	insensitive := RequireNew(options, Options.CaseMatching(CaseInsensitive))
+	assertRoute(t, insensitive, "GET", "/API/v1/users/AbC", 200, "id=AbC", "")
+	assertRoute(t, insensitive, "PUT", "/api/V1/users/42", 405, "Method Not Allowed\n", "GET, POST")
+	assertRoute(t, insensitive, "GET", "/WIDE/Golf", 200, "golf", "")
+	assertRoute(t, insensitive, "GET", "/Reports/REPORT-2024.CSV", 200, "year=2024", "")
+	assertRoute(t, insensitive, "GET", "/Files/Some/Path.TXT", 200, "*=Some/Path.TXT", "")
+	assertRoute(t, insensitive, "GET", "/exact", 200, "lower", "")
+	assertRoute(t, insensitive, "GET", "/EXACT", 200, "upper", "")
+	assertRoute(t, insensitive, "GET", "/Exact", 200, "lower", "")
+	assertRoute(t, insensitive, "GET", "/WIDE/golfer", 404, "Not Found\n", "")
+
+	redirecting := RequireNew(options, Options.CaseMatching(CaseInsensitiveRedirect))
+	assertRedirect(t, redirecting, "GET", "/API/v1/users/AbC", 301, "/api/v1/Users/AbC?query=value")
+	assertRedirect(t, redirecting, "POST", "/api/v1/users/42", 308, "/api/v1/Users/42?query=value")
+	assertRedirect(t, redirecting, "GET", "/WIDE/Golf", 301, "/wide/golf?query=value")
+	assertRedirect(t, redirecting, "GET", "/reports/REPORT-2024.CSV", 301, "/reports/report-2024.csv?query=value")
+	assertRedirect(t, redirecting, "GET", "/FILES/Some/Path.TXT", 301, "/files/Some/Path.TXT?query=value")
+	assertRedirect(t, redirecting, "GET", "/api/v1/Users/AbC", 200, "")
+	assertRoute(t, redirecting, "PUT", "/API/v1/users/42", 405, "Method Not Allowed\n", "GET, POST")
+
+	combined := RequireNew(options, Options.CaseMatching(CaseInsensitiveRedirect), Options.TrailingSlash(TrailingSlashRedirect))
+	assertRedirect(t, combined, "GET", "/WIDE/Golf/", 301, "/wide/golf?query=value")
+	assertRoute(t, combined, "PUT", "/WIDE/Golf/", 405, "Method Not Allowed\n", "GET")
+}
+func TestEncodedSlashPolicy(t *testing.T) {
+	var captured Params
+	routes := Options.Routes(
+		ParseRoute("GET", "/packages/:name", paramsHandler{"name"}),
+		ParseRoute("GET", "/packages/:scope/:name", paramsHandler{"scope", "name"}),
+		ParseRoute("GET", "/files/*", paramsHandler{WildcardParam}),
+		ParseRoute("GET", "/raw/:name", http.HandlerFunc(func(_ http.ResponseWriter, request *http.Request) {
+			captured = ParamsFromContext(request.Context())
+		})),
+	)
+
+	preserving := RequireNew(routes)
+	assertRoute(t, preserving, "GET", "/packages/@scope%2Fpkg", 200, "name=@scope%2Fpkg", "")
+	assertRoute(t, preserving, "GET", "/packages/@scope%2fpkg", 200, "name=@scope%2Fpkg", "")
+	assertRoute(t, preserving, "GET", "/files/a%2Fb/c", 200, "*=a%2Fb/c", "")
+
+	decodingValues := RequireNew(routes, Options.EncodedSlashes(EncodedSlashDecodeValues))
+	assertRoute(t, decodingValues, "GET", "/packages/@scope%2Fpkg", 200, "name=@scope/pkg", "")
+	assertRoute(t, decodingValues, "GET", "/packages/caf%C3%A9", 200, "name=café", "")
+	assertRoute(t, decodingValues, "GET", "/files/a%2Fb/c", 200, "*=a/b/c", "")
+	decodingValues.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/raw/a%2Fb", nil))
+	Assert(t).That(captured).Equals(Params{{Name: "name", Value: "a/b", Raw: "a%2Fb"}})
+	assertRoute(t, decodingValues, "GET", "/files/..%2F..%2Fetc%2Fpasswd", 400, "Bad Request\n", "")
+	assertRoute(t, decodingValues, "GET", "/files/a/%2e%2e%2fsecret", 400, "Bad Request\n", "")
+	assertRoute(t, decodingValues, "GET", "/packages/a%2F.", 400, "Bad Request\n", "")
+	assertRoute(t, decodingValues, "GET", "/files/a..%2Fb", 200, "*=a../b", "")
+
+	decodingPath := RequireNew(routes, Options.EncodedSlashes(EncodedSlashDecodePath))
+	assertRoute(t, decodingPath, "GET", "/packages/@scope%2Fpkg", 200, "scope=@scope,name=pkg", "")
+	assertRoute(t, decodingPath, "GET", "/packages/pkg%20name", 200, "name=pkg name", "")
+
+	rejecting := RequireNew(routes, Options.EncodedSlashes(EncodedSlashReject))
+	assertRoute(t, rejecting, "GET", "/packages/@scope%2Fpkg", 400, "Bad Request\n", "")
+	assertRoute(t, rejecting, "GET", "/files/a%2fb", 400, "Bad Request\n", "")
+	assertRoute(t, rejecting, "GET", "/packages/pkg", 200, "name=pkg", "")
+
+	monitor := &recordingMonitor{}
+	assertRoute(t, RequireNew(routes, Options.EncodedSlashes(EncodedSlashReject), Options.Monitor(monitor)),
+		"GET", "/files/a%2fb", 400, "Bad Request\n", "")
+	assertRoute(t, RequireNew(routes, Options.EncodedSlashes(EncodedSlashDecodeValues), Options.Monitor(monitor)),
+		"GET", "/files/..%2Fetc", 400, "Bad Request\n", "")
+	Assert(t).That(monitor.rejected).Equals([]int{http.StatusBadRequest, http.StatusBadRequest})
+}
+func TestRegisteredMethods(t *testing.T) {
+	restoreMethodRegistry(t)
+	propfind, err := RegisterMethod("PROPFIND")
+	Assert(t).That(err).IsNil()
+	again, _ := RegisterMethod("PROPFIND")
+	Assert(t).That(again).Equals(propfind)
+	purge, _ := RegisterMethod("PURGE")
+	Assert(t).That(ParseMethods("propfind|GET")).Equals(propfind | MethodGet)
+	Assert(t).That((MethodGet | propfind | purge).String()).Equals("GET|PROPFIND|PURGE")
+	Assert(t).That((MethodPut | purge).HeaderValue()).Equals("PUT, PURGE")
+
+	_, err1 := RegisterMethod("lock")
+	_, err2 := RegisterMethod("ANY")
+	_, err3 := RegisterMethod("")
+	Assert(t).That(err1).Equals(ErrInvalidMethod)
+	Assert(t).That(err2).Equals(ErrInvalidMethod)
+	Assert(t).That(err3).Equals(ErrInvalidMethod)
+
+	router := RequireNew(
+		Options.AddRoute("GET|PROPFIND", "/dav/:name", paramsHandler{"name"}),
+		Options.AddRoute("PURGE", "/cache/*", patternHandler{}),
+	)
+	assertRoute(t, router, "PROPFIND", "/dav/file", 200, "name=file", "")
+	assertRoute(t, router, "PURGE", "/dav/file", 405, "Method Not Allowed\n", "GET, PROPFIND")
+	assertRoute(t, router, "PURGE", "/cache/a/b", 200, "PURGE /cache/*", "")
+	assertRoute(t, router, "MKCOL", "/dav/file", 501, "Not Implemented\n", "")
+
+	monitor := &recordingMonitor{}
+	monitored := RequireNew(Options.AddRoute("GET", "/dav/:name", paramsHandler{"name"}), Options.Monitor(monitor))
+	assertRoute(t, monitored, "MKCOL", "/dav/file", 501, "Not Implemented\n", "")
+	assertRoute(t, monitored, "PUT", "/dav/file", 405, "Method Not Allowed\n", "GET")
+	Assert(t).That(monitor.rejected).Equals([]int{http.StatusNotImplemented})
+	Assert(t).That(monitor.methodNotAllowed).Equals(1)
+
+	_, err = New(Options.AddRoute("MKCOL", "/dav/:name", paramsHandler{"name"}))
+	Assert(t).That(err).Wraps(ErrUnknownMethod) // not registered, so not parsed
+}
+func TestAnyMethodRoute(t *testing.T) {
+	router := RequireNew(
+		Options.AddRoute("ANY", "/proxy/*", patternHandler{}),
+		Options.AddRoute("GET", "/proxy/status", simpleHandler("status")),
+		Options.AddRoute("GET", "/users", simpleHandler("get")),
+		Options.AddRoute("ANY", "/users", simpleHandler("any")),
+	)
+
+	assertRoute(t, router, "DELETE", "/proxy/a/b", 200, "/proxy/*", "")
+	assertRoute(t, router, "BOGUS", "/proxy/a/b", 200, "/proxy/*", "")
+	assertRoute(t, router, "GET", "/proxy/status", 200, "status", "")
+	assertRoute(t, router, "POST", "/proxy/status", 200, "/proxy/*", "") // falls back to the catch-all wildcard
+	assertRoute(t, router, "GET", "/users", 200, "get", "")
+	assertRoute(t, router, "PATCH", "/users", 200, "any", "")
+	assertRoute(t, router, "BOGUS", "/missing", 501, "Not Implemented\n", "")
+
+	Assert(t).That(ParseMethods("ANY")).Equals(MethodAny)
+	Assert(t).That((MethodGet | MethodAny).String()).Equals("GET|ANY")
+
+	_, err := New(Options.AddRoute("ANY", "/users", simpleHandler("any")), Options.AddRoute("ANY", "/users", simpleHandler("any")))
+	Assert(t).That(err).Wraps(ErrRouteExists)
+}
+func TestUnrecognizedMethodWithoutNotImplemented(t *testing.T) {
+	router := RequireNew(Options.AddRoute("GET", "/users", simpleHandler("users")), Options.NotImplemented(nil))
+
+	assertRoute(t, router, "BOGUS", "/users", 405, "Method Not Allowed\n", "GET")
+	assertRoute(t, router, "BOGUS", "/missing", 404, "Not Found\n", "")
+}
+func TestAutomaticOptions(t *testing.T) {
+	routes := Options.Routes(
+		ParseRoute("GET|POST", "/users", simpleHandler("users")),
+		ParseRoute("OPTIONS", "/custom", simpleHandler("custom")),
+		ParseRoute("DELETE", "/users/:id", paramsHandler{"id"}),
+	)
+
+	manual := RequireNew(routes)
+	assertRoute(t, manual, "OPTIONS", "/users", 405, "Method Not Allowed\n", "GET, POST")
+
+	automatic := RequireNew(routes, Options.AutomaticOptions(true))
+	assertRoute(t, automatic, "OPTIONS", "/users", 204, "", "GET, POST, OPTIONS")
+	assertRoute(t, automatic, "OPTIONS", "/users/42", 204, "", "DELETE, OPTIONS")
+	assertRoute(t, automatic, "OPTIONS", "/custom", 200, "custom", "") // an explicit route wins
+	assertRoute(t, automatic, "OPTIONS", "/missing", 404, "Not Found\n", "")
+	assertRoute(t, automatic, "PUT", "/users", 405, "Method Not Allowed\n", "GET, POST, OPTIONS") // as OPTIONS advertises
+	assertRoute(t, automatic, "GET", "/users/42", 405, "Method Not Allowed\n", "DELETE, OPTIONS")
+
+	recorder := httptest.NewRecorder()
+	automatic.ServeHTTP(recorder, httptest.NewRequest("OPTIONS", "*", nil))
+	Assert(t).That(recorder.Code).Equals(http.StatusNoContent)
+	Assert(t).That(recorder.Header().Get("Allow")).Equals("GET, POST, DELETE, OPTIONS")
+
+	recorder = httptest.NewRecorder()
+	manual.ServeHTTP(recorder, httptest.NewRequest("OPTIONS", "*", nil))
+	Assert(t).That(recorder.Code).Equals(http.StatusNotFound)
+
+	serverAllow := func(router http.Handler) string {
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, httptest.NewRequest("OPTIONS", "*", nil))
+		return recorder.Header().Get("Allow")
+	}
+	reloadable, _ := NewReloadable(routes, Options.AutomaticOptions(true), Options.ImplicitHead(true))
+	Assert(t).That(serverAllow(reloadable)).Equals("GET, HEAD, POST, DELETE, OPTIONS")
+	patch := ParseRoute("PATCH", "api.example.com/users/:id/name", simpleHandler("renamed"))
+	Assert(t).That(reloadable.Add(patch)).Equals(nil)
+	Assert(t).That(serverAllow(reloadable)).Equals("GET, HEAD, POST, DELETE, OPTIONS, PATCH")
+	Assert(t).That(reloadable.Remove(ParseRoute("DELETE", "/users/:id", nil), patch)).Equals(nil)
+	Assert(t).That(serverAllow(reloadable)).Equals("GET, HEAD, POST, OPTIONS")
+}
+func TestImplicitHead(t *testing.T) {
+	routes := Options.Routes(
+		ParseRoute("GET", "/health", http.HandlerFunc(func(response http.ResponseWriter, _ *http.Request) {
+			response.Header().Set("Content-Length", "2")
+			_, _ = io.WriteString(response, "ok")
+		})),
+		ParseRoute("GET", "/users/:id", paramsHandler{"id"}),
+		ParseRoute("HEAD", "/users/:id", simpleHandler("")),
+		ParseRoute("GET|POST", "/files/*", patternHandler{}),
+		ParseRoute("POST", "/upload", simpleHandler("upload")),
+	)
+
+	explicit := RequireNew(routes)
+	assertRoute(t, explicit, "HEAD", "/health", 405, "Method Not Allowed\n", "GET")
+
+	implicit := RequireNew(routes, Options.ImplicitHead(true), Options.AutomaticOptions(true))
+	assertRoute(t, implicit, "HEAD", "/health", 200, "", "")
+	assertRoute(t, implicit, "HEAD", "/files/a/b", 200, "", "")
+	assertRoute(t, implicit, "HEAD", "/upload", 405, "Method Not Allowed\n", "POST, OPTIONS")
+	assertRoute(t, implicit, "PUT", "/files/a", 405, "Method Not Allowed\n", "GET, HEAD, POST, OPTIONS")
+	assertRoute(t, implicit, "OPTIONS", "/health", 204, "", "GET, HEAD, OPTIONS")
+
+	recorder := httptest.NewRecorder()
+	implicit.ServeHTTP(recorder, httptest.NewRequest("HEAD", "/health", nil))
+	Assert(t).That(recorder.Header().Get("Content-Length")).Equals("2")
+	Assert(t).That(recorder.Body.Len()).Equals(0)
+
+	var served string
+	override := RequireNew(Options.ImplicitHead(true),
+		Options.AddRoute("GET", "/resource", simpleHandler("get")),
+		Options.AddRoute("HEAD", "/resource", http.HandlerFunc(func(http.ResponseWriter, *http.Request) { served = "head" })))
+	override.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("HEAD", "/resource", nil))
+	Assert(t).That(served).Equals("head")
+}
+func TestHostRouting(t *testing.T) {
+	router := RequireNew(Options.Routes(
+		ParseRoute("GET", "api.example.com/users", simpleHandler("api")),
+		ParseRoute("GET", "API.example.com:8443/users/:id", paramsHandler{"id"}), // same host: case and port are ignored
+		ParseRoute("GET", "{tenant}.example.com/users/:id", paramsHandler{"tenant", "id"}),
+		ParseRoute("GET", "{tenant}.{region}.example.com/", paramsHandler{"tenant", "region"}),
+		ParseRoute("GET", "*.example.com/users", patternHandler{}),
+		ParseRoute("GET", "/users", simpleHandler("default")),
+	))
+
+	assertHostRoute(t, router, "api.example.com", "/users", 200, "api")
+	assertHostRoute(t, router, "Api.Example.com:8080", "/users/42", 200, "id=42")
+	assertHostRoute(t, router, "api.example.com.", "/users", 200, "api")
+	assertHostRoute(t, router, "acme.example.com", "/users/42", 200, "tenant=acme,id=42")
+	assertHostRoute(t, router, "acme.eu.example.com", "/", 200, "tenant=acme,region=eu")
+	assertHostRoute(t, router, "acme.example.com", "/users", 404, "Not Found\n") // the host's own routes only
+	assertHostRoute(t, router, "a.b.c.example.com", "/users", 200, "GET *.example.com/users")
+	assertHostRoute(t, router, "example.com", "/users", 200, "default")
+	assertHostRoute(t, router, "other.org:80", "/users", 200, "default")
+	assertHostRoute(t, router, "[::1]:8080", "/users", 200, "default")
+
+	_, err1 := New(Options.Routes(Route{AllowedMethods: MethodGet, Host: "bad_host!", Path: "/users", Handler: simpleHandler("")}))
+	_, err2 := New(Options.AddRoute("GET", "api.*.com/users", simpleHandler("")))
+	_, err3 := New(Options.AddRoute("GET", "{}.example.com/users", simpleHandler("")))
+	_, err4 := New(Options.AddRoute("GET", "api..com/users", simpleHandler("")))
+	_, err5 := New(Options.Routes(Route{AllowedMethods: MethodGet, Host: "*", Path: "/users", Handler: simpleHandler("")}))
+	Assert(t).That(err1).Wraps(ErrMalformedHost)
+	Assert(t).That(err2).Wraps(ErrMalformedHost)
+	Assert(t).That(err3).Wraps(ErrMalformedHost)
+	Assert(t).That(err4).Wraps(ErrMalformedHost)
+	Assert(t).That(err5).Wraps(ErrMalformedHost)
+
+	// Only text that looks like a host is taken for one; anything else is a path missing its leading slash.
+	Assert(t).That(ParseRoute("GET", "localhost/x", nil).Host).Equals("localhost")
+	Assert(t).That(ParseRoute("GET", "intranet:8080/x", nil).Host).Equals("intranet:8080")
+	Assert(t).That(ParseRoute("GET", "{tenant}/x", nil).Host).Equals("{tenant}")
+	typo := ParseRoute("GET", "users/x", nil)
+	Assert(t).That(typo.Host).Equals("")
+	Assert(t).That(typo.Path).Equals("users/x")
+	_, err6 := New(Options.AddRoute("GET", "users/x", simpleHandler("")))
+	_, err7 := New(Options.AddRoute("GET", ":id/x", simpleHandler("")))
+	Assert(t).That(err6).Wraps(ErrMalformedPath)
+	Assert(t).That(err7).Wraps(ErrMalformedPath)
+}
+func assertHostRoute(t *testing.T, router http.Handler, host, path string, expectedStatus int, expectedBody string) {
+	t.Helper()
+
+	request := httptest.NewRequest("GET", path, nil)
+	request.Host = host
+	recorder := httptest.NewRecorder()
+
+	router.ServeHTTP(recorder, request)
+
+	Assert(t).That(recorder.Code).Equals(expectedStatus)
+	Assert(t).That(recorder.Body.String()).Equals(expectedBody)
+}
+func TestVersionedRoutes(t *testing.T) {
+	routes := Options.Routes(
+		Route{AllowedMethods: MethodGet, Path: "/users/:id", Version: "1", Handler: simpleHandler("v1")},
+		Route{AllowedMethods: MethodGet | MethodPut, Path: "/users/:id", Version: "2", Handler: simpleHandler("v2")},
+		Route{AllowedMethods: MethodGet, Path: "/users/:id", Version: "1.5", Handler: simpleHandler("v1.5")},
+		ParseRoute("GET", "/health", simpleHandler("health")),
+	)
+
+	byAccept := RequireNew(routes, Options.Versioning(VersionFromAccept("acme")), Options.DefaultVersion("1"))
+	assertRouteWithHeader(t, byAccept, "/users/42", "Accept", "application/vnd.acme.v2+json", 200, "v2", "Accept")
+	assertRouteWithHeader(t, byAccept, "/users/42", "Accept", "text/html, application/vnd.acme.v1.5+json;q=0.9", 200, "v1.5", "Accept")
+	assertRouteWithHeader(t, byAccept, "/users/42", "Accept", "application/json", 200, "v1", "Accept") // the default
+	assertRouteWithHeader(t, byAccept, "/users/42", "Accept", "application/vnd.acme.v3+json", 406, "Not Acceptable\n", "Accept")
+	assertRouteWithHeader(t, byAccept, "/health", "Accept", "application/vnd.acme.v3+json", 200, "health", "")
+
+	byHeader := RequireNew(routes, Options.Versioning(VersionFromHeader("x-api-version")))
+	assertRouteWithHeader(t, byHeader, "/users/42", "X-API-Version", "v1", 200, "v1", "X-Api-Version")
+	assertRouteWithHeader(t, byHeader, "/users/42", "X-API-Version", "", 200, "v2", "X-Api-Version") // the latest
+	assertRouteWithHeader(t, byHeader, "/users/42", "X-API-Version", "latest", 200, "v2", "X-Api-Version")
+	assertRouteWithHeader(t, byHeader, "/users/42", "X-API-Version", "9", 400, "Bad Request\n", "X-Api-Version")
+
+	monitor := &recordingMonitor{}
+	byQuery := RequireNew(routes, Options.Versioning(VersionFromQuery("version")), Options.DefaultVersion("7"), Options.Monitor(monitor))
+	assertRouteWithHeader(t, byQuery, "/users/42?version=1.5", "", "", 200, "v1.5", "")
+	assertRouteWithHeader(t, byQuery, "/users/42", "", "", 200, "v2", "") // the default isn't served, so the latest is
+	assertRouteWithHeader(t, byQuery, "/users/42?version=2.1", "", "", 400, "Bad Request\n", "")
+	Assert(t).That(monitor.rejected).Equals([]int{http.StatusBadRequest})
+
+	unconfigured := RequireNew(routes, Options.ImplicitHead(true))
+	assertRoute(t, unconfigured, "GET", "/users/42", 200, "v2", "")
+	assertRoute(t, unconfigured, "HEAD", "/users/42", 200, "", "")
+	assertRoute(t, unconfigured, "DELETE", "/users/42", 405, "Method Not Allowed\n", "GET, HEAD, PUT")
+
+	_, err1 := New(Options.Routes(
+		Route{AllowedMethods: MethodGet, Path: "/users", Version: "1", Handler: simpleHandler("")},
+		Route{AllowedMethods: MethodGet, Path: "/users", Version: "v1.0", Handler: simpleHandler("")}))
+	_, err2 := New(Options.Routes(
+		Route{AllowedMethods: MethodGet, Path: "/users", Version: "1", Handler: simpleHandler("")},
+		Route{AllowedMethods: MethodGet, Path: "/users", Handler: simpleHandler("")}))
+	Assert(t).That(err1).Wraps(ErrRouteExists)
+	Assert(t).That(err2).Wraps(ErrRouteExists)
+
+	mixed := RequireNew(Options.Versioning(VersionFromQuery("version")), Options.Routes(
+		Route{AllowedMethods: MethodGet, Path: "/x", Version: "1", Handler: simpleHandler("a"), Predicates: []Predicate{QueryPresent("p")}},
+		Route{AllowedMethods: MethodGet, Path: "/x", Version: "2", Handler: simpleHandler("b"), Predicates: []Predicate{QueryPresent("q")}},
+		Route{AllowedMethods: MethodGet, Path: "/x", Version: "3", Handler: simpleHandler("c")},
+	))
+	assertRouteWithHeader(t, mixed, "/x?p&q&version=2", "", "", 200, "b", "")
+	assertRouteWithHeader(t, mixed, "/x?p&version=3", "", "", 200, "c", "")
+	assertRouteWithHeader(t, mixed, "/x?p&version=1", "", "", 200, "a", "")
+	assertRouteWithHeader(t, mixed, "/x?p&q", "", "", 200, "c", "") // the latest of those whose predicates hold
+	assertRouteWithHeader(t, mixed, "/x?p&version=2", "", "", 400, "Bad Request\n", "")
+}
+func assertRouteWithHeader(t *testing.T, router http.Handler, target, header, value string, expectedStatus int, expectedBody, expectedVary string) {
+	t.Helper()
+
+	request := httptest.NewRequest("GET", target, nil)
+	if len(header) > 0 {
+		request.Header.Set(header, value)
+	}
+	recorder := httptest.NewRecorder()
+
+	router.ServeHTTP(recorder, request)
+
+	Assert(t).That(recorder.Code).Equals(expectedStatus)
+	Assert(t).That(recorder.Body.String()).Equals(expectedBody)
+	Assert(t).That(recorder.Header().Get("Vary")).Equals(expectedVary)
+}
+func TestRoutePredicates(t *testing.T) {
+	routes := Options.Routes(
+		Route{AllowedMethods: MethodGet, Path: "/chat", Handler: simpleHandler("socket"),
+			Predicates: []Predicate{HeaderEquals("upgrade", "websocket")}},
+		Route{AllowedMethods: MethodGet, Path: "/chat", Handler: simpleHandler("plain")},
+		Route{AllowedMethods: MethodGet, Path: "/events/:id", Handler: simpleHandler("stream"),
+			Predicates: []Predicate{QueryEquals("stream", "true")}},
+		Route{AllowedMethods: MethodGet, Path: "/events/:id", Handler: simpleHandler("debug"),
+			Predicates: []Predicate{QueryPresent("debug"), HeaderMatches("User-Agent", "^curl/")}},
+		Route{AllowedMethods: MethodGet, Path: "/events/:id", Handler: simpleHandler("internal"),
+			Predicates: []Predicate{PredicateFunc("internal", func(request *http.Request) bool { return request.Header.Get("X-Internal") == "1" })}},
+	)
+
+	router := RequireNew(routes)
+	assertRouteWithHeader(t, router, "/chat", "Upgrade", "WebSocket", 200, "socket", "")
+	assertRouteWithHeader(t, router, "/chat", "", "", 200, "plain", "")
+	assertRouteWithHeader(t, router, "/events/1?stream=true", "", "", 200, "stream", "")
+	assertRouteWithHeader(t, router, "/events/1?stream=true&debug", "User-Agent", "curl/8.0", 200, "stream", "") // registration order
+	assertRouteWithHeader(t, router, "/events/1?debug", "User-Agent", "curl/8.0", 200, "debug", "")
+	assertRouteWithHeader(t, router, "/events/1?debug", "User-Agent", "Mozilla/5.0", 404, "Not Found\n", "")
+	assertRouteWithHeader(t, router, "/events/1", "X-Internal", "1", 200, "internal", "")
+	assertRouteWithHeader(t, router, "/events/1", "", "", 404, "Not Found\n", "")
+	assertRoute(t, router, "POST", "/events/1", 405, "Method Not Allowed\n", "GET")
+
+	monitor := &recordingMonitor{}
+	configured := RequireNew(routes, Options.PredicateMismatch(statusHandler(http.StatusPreconditionFailed)), Options.Monitor(monitor))
+	assertRouteWithHeader(t, configured, "/events/1", "", "", 412, "Precondition Failed\n", "")
+	Assert(t).That(monitor.notFound).Equals(1)
+
+	_, err1 := New(Options.Routes(
+		Route{AllowedMethods: MethodGet, Path: "/chat", Handler: simpleHandler(""), Predicates: []Predicate{QueryPresent("a"), QueryPresent("b")}},
+		Route{AllowedMethods: MethodGet, Path: "/chat", Handler: simpleHandler(""), Predicates: []Predicate{QueryPresent("b"), QueryPresent("a")}}))
+	_, err2 := New(Options.Routes(
+		Route{AllowedMethods: MethodGet, Path: "/chat", Handler: simpleHandler(""), Predicates: []Predicate{HeaderMatches("Upgrade", "[")}}))
+	_, err3 := New(Options.Routes(
+		Route{AllowedMethods: MethodGet, Path: "/chat", Handler: simpleHandler(""), Predicates: []Predicate{PredicateFunc("nil", nil)}}))
+	_, err4 := New(Options.Routes(
+		Route{AllowedMethods: MethodGet, Path: "/chat", Handler: simpleHandler(""), Predicates: []Predicate{{}}}))
+	Assert(t).That(err1).Wraps(ErrRouteExists)
+	Assert(t).That(err2).Wraps(ErrInvalidPredicate)
+	Assert(t).That(err3).Wraps(ErrInvalidPredicate)
+	Assert(t).That(err4).Wraps(ErrInvalidPredicate)
+}
+func TestRouteGroups(t *testing.T) {
+	tag := func(name string) func(http.Handler) http.Handler {
+		return func(inner http.Handler) http.Handler {
+			return http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+				_, _ = io.WriteString(response, name+">")
+				inner.ServeHTTP(response, request)
+			})
+		}
+	}
+
+	api := NewGroup("/api", tag("api")).
+		Add("GET", "/status", simpleHandler("status"))
+	admin := api.Group("/admin", tag("auth"), tag("audit")).
+		Add("GET|POST", "/users|/users/:id", paramsHandler{"id"}).
+		NotFound(simpleHandler("admin-missing")).
+		MethodNotAllowed(statusHandler(http.StatusTeapot))
+	admin.Routes(ParseRoute("DELETE", "/users/:id", simpleHandler("deleted")))
+
+	routes := api.Build()
+	Assert(t).That(len(routes)).Equals(4)
+	Assert(t).That(routes[0].String()).Equals("GET /api/status")
+	Assert(t).That(routes[1].String()).Equals("GET|POST /api/admin/users")
+	Assert(t).That(routes[3].String()).Equals("DELETE /api/admin/users/:id")
+
+	monitor := &recordingMonitor{}
+	router := RequireNew(Options.Routes(routes...), Options.AddRoute("GET", "/other", simpleHandler("other")),
+		Options.Monitor(monitor))
+	assertRoute(t, router, "GET", "/api/status", 200, "api>status", "")
+	assertRoute(t, router, "GET", "/api/admin/users/42", 200, "api>auth>audit>id=42", "")
+	assertRoute(t, router, "DELETE", "/api/admin/users/42", 200, "api>auth>audit>deleted", "")
+	assertRoute(t, router, "GET", "/api/admin/missing", 200, "admin-missing", "")
+	assertRoute(t, router, "GET", "/api/admin", 200, "admin-missing", "")
+	assertRoute(t, router, "GET", "/api/administrators", 404, "Not Found\n", "")
+	assertRoute(t, router, "PUT", "/api/admin/users", 418, "I'm a teapot\n", "GET, POST")
+	assertRoute(t, router, "PUT", "/api/status", 405, "Method Not Allowed\n", "GET") // outside the group
+	assertRoute(t, router, "GET", "/api/missing", 404, "Not Found\n", "")
+	assertRoute(t, router, "GET", "/other", 200, "other", "")
+	Assert(t).That(monitor.notFound).Equals(4)
+	Assert(t).That(monitor.methodNotAllowed).Equals(2)
+}
+func TestRouteGroupsFollowRouterPolicies(t *testing.T) {
+	tenants := NewGroup("/tenants/:tenant{int}").
+		Add("GET", "/users/:id", paramsHandler{"tenant", "id"}).
+		NotFound(simpleHandler("tenant-missing"))
+	tenants.Group("/billing").
+		Add("GET", "/invoices", simpleHandler("invoices")).
+		MethodNotAllowed(statusHandler(http.StatusTeapot))
+	hosted := NewGroup("/api").
+		Routes(Route{AllowedMethods: MethodGet, Host: "api.example.com", Path: "/users", Handler: simpleHandler("users")}).
+		NotFound(simpleHandler("api-missing"))
+
+	router := RequireNew(
+		Options.Routes(tenants.Build()...),
+		Options.Routes(hosted.Build()...),
+		Options.TrailingSlash(TrailingSlashRedirect),
+		Options.CaseMatching(CaseInsensitiveRedirect),
+	)
+	assertRoute(t, router, "GET", "/tenants/7/users/42", 200, "tenant=7,id=42", "")
+	assertRoute(t, router, "GET", "/tenants/7/missing", 200, "tenant-missing", "")
+	assertRoute(t, router, "GET", "/TENANTS/7/missing", 200, "tenant-missing", "")
+	assertRoute(t, router, "GET", "/tenants/x/missing", 404, "Not Found\n", "") // the prefix's constraint fails
+	assertRedirect(t, router, "GET", "/tenants/7/users/42/", 301, "/tenants/7/users/42?query=value")
+	assertRedirect(t, router, "GET", "/Tenants/7/Users/42", 301, "/tenants/7/users/42?query=value")
+	assertRoute(t, router, "POST", "/tenants/7/billing/invoices", 418, "I'm a teapot\n", "GET")
+	assertRoute(t, router, "POST", "/tenants/7/users/42", 405, "Method Not Allowed\n", "GET")
+	assertRoute(t, router, "GET", "/tenants/7/billing/missing", 200, "tenant-missing", "") // the enclosing group's
+
+	assertHostRoute(t, router, "api.example.com", "/api/missing", 200, "api-missing")
+	assertHostRoute(t, router, "other.example.com", "/api/missing", 404, "Not Found\n")
+
+	reloadable, _ := NewReloadable()
+	Assert(t).That(reloadable.Add(hosted.Build()...)).Equals(nil)
+	assertHostRoute(t, reloadable, "api.example.com", "/api/missing", 200, "api-missing")
+	Assert(t).That(reloadable.Remove(hosted.Build()...)).Equals(nil)
+	assertHostRoute(t, reloadable, "api.example.com", "/api/missing", 404, "Not Found\n") // no route of the group is left
+}
+func TestMiddlewareChains(t *testing.T) {
+	tag := func(name string) func(http.Handler) http.Handler {
+		return func(inner http.Handler) http.Handler {
+			return http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+				_, _ = io.WriteString(response, name+"("+request.Pattern+")>")
+				inner.ServeHTTP(response, request)
+			})
+		}
+	}
+	var seen int
+	legacy := func(inner http.Handler) http.Handler {
+		return http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+			seen++
+			if request.URL.Path == "/panic" {
+				panic("middleware")
+			}
+			request.URL.Path = strings.TrimPrefix(request.URL.Path, "/legacy")
+			request.RequestURI = strings.TrimPrefix(request.RequestURI, "/legacy")
+			inner.ServeHTTP(response, request)
+		})
+	}
+
+	route := ParseRoute("GET", "/users/:id", simpleHandler("user"))
+	route.Middleware = []func(http.Handler) http.Handler{tag("route")}
+	group := NewGroup("/api", tag("group")).Routes(route)
+
+	router := RequireNew(
+		Options.Routes(group.Build()...),
+		Options.Routes(route),
+		Options.Middleware(tag("global")),
+		Options.PreRoutingMiddleware(legacy),
+		Options.Recovery(RecoveryHandler),
+	)
+	assertRoute(t, router, "GET", "/users/1", 200, "global(GET /users/:id)>route(GET /users/:id)>user", "")
+	assertRoute(t, router, "GET", "/legacy/users/1", 200, "global(GET /users/:id)>route(GET /users/:id)>user", "")
+	assertRoute(t, router, "GET", "/api/users/1", 200,
+		"global(GET /api/users/:id)>group(GET /api/users/:id)>route(GET /api/users/:id)>user", "")
+	assertRoute(t, router, "GET", "/missing", 404, "Not Found\n", "")
+	assertRoute(t, router, "GET", "/panic", 500, "Internal Server Error\n", "")
+	Assert(t).That(seen).Equals(5) // before resolution, whether or not a route matched
+}
+func TestMount(t *testing.T) {
+	echo := http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+		_, _ = fmt.Fprintf(response, "%s|%s|%s|%s", request.URL.Path, request.URL.RawPath, request.RequestURI,
+			MountPrefixFromContext(request.Context()))
+	})
+	users := RequireNew(Options.AddRoute("GET", "/users/:id", echo))
+
+	router := RequireNew(
+		Options.Mount("/files/", echo),
+		Options.Mount("/admin", echo),
+		Options.Mount("/tenants/:tenant/admin", users),
+		Options.AddRoute("GET", "/other", simpleHandler("other")),
+		Options.NotFound(simpleHandler("parent-missing")),
+		Options.MethodNotAllowed(statusHandler(http.StatusTeapot)),
+	)
+	assertRoute(t, router, "GET", "/files", 200, "/||/?query=value#hash|/files", "")
+	assertRoute(t, router, "GET", "/files/", 200, "/||/?query=value#hash|/files", "")
+	assertRoute(t, router, "POST", "/files/a/b.txt", 200, "/a/b.txt||/a/b.txt?query=value#hash|/files", "")
+	assertRoute(t, router, "GET", "/files/a%2Fb", 200, "/a/b|/a%2Fb|/a%2Fb?query=value#hash|/files", "")
+	assertRoute(t, router, "GET", "/tenants/acme/admin/users/42", 200,
+		"/users/42||/users/42?query=value#hash|/tenants/acme/admin", "")
+	assertRoute(t, router, "GET", "/tenants/acme/admin/missing", 200, "parent-missing", "")
+	assertRoute(t, router, "PUT", "/tenants/acme/admin/users/42", 418, "I'm a teapot\n", "GET")
+	assertRoute(t, router, "GET", "/other", 200, "other", "")
+	assertRoute(t, router, "GET", "/admin", 200, "/||/?query=value#hash|/admin", "")
+
+	nested := RequireNew(Options.Mount("/outer", router))
+	assertRoute(t, nested, "GET", "/outer/files/x", 200, "/x||/x?query=value#hash|/outer/files", "")
+	assertRoute(t, nested, "GET", "/outer/tenants/acme/admin/missing", 200, "parent-missing", "")
+	assertRoute(t, nested, "GET", "/outer/admin", 200, "/||/?query=value#hash|/outer/admin", "")
+	assertRoute(t, nested, "GET", "/outer/files", 200, "/||/?query=value#hash|/outer/files", "")
+	assertRoute(t, nested, "GET", "/outer/tenants/acme/admin", 200, "parent-missing", "")
+
+	inspect := http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+		route, _, ok := RouteFromContext(request.Context())
+		_, _ = fmt.Fprintf(response, "%v|%v|%v", ParamsFromContext(request.Context()), route, ok)
+	})
+	api := RequireNew(Options.Mount("/api", RequireNew(Options.AddRoute("GET", "/users", inspect))))
+	assertRoute(t, api, "GET", "/api/users", 200, "[]|GET /users|true", "") // the inner route, not the outer one
+
+	redirecting := RequireNew(Options.Mount("/api", RequireNew(
+		Options.AddRoute("GET", "/users", simpleHandler("users")),
+		Options.TrailingSlash(TrailingSlashRedirect),
+		Options.CaseMatching(CaseInsensitiveRedirect),
+		Options.NormalizePath(PathDuplicateSlashes, NormalizeRedirect))))
+	assertRedirect(t, redirecting, "GET", "/api/users/", 301, "/api/users?query=value")
+	assertRedirect(t, redirecting, "GET", "/api/USERS", 301, "/api/users?query=value")
+	assertRedirect(t, redirecting, "GET", "/api//users", 301, "/api/users?query=value")
+
+	_, err := New(Options.Mount("/nil", nil))
+	Assert(t).That(err).Wraps(ErrNilHandler)
+}
+func TestNamedRoutes(t *testing.T) {
+	named := func(name, methods, path string) Route {
+		route := ParseRoute(methods, path, simpleHandler(name))
+		route.Name = name
+		return route
+	}
+	router := RequireNew(
+		Options.Routes(
+			named("home", "GET", "/"),
+			named("orders", "GET", "/users/:id/orders"),
+			named("orders", "POST", "/users/:id/orders"),
+			named("order", "GET", "/orders/:id{int}"),
+			named("file", "GET", "/files/:name.:ext"),
+			named("static", "GET", "/static/*"),
+			named("cafe", "GET", "/café/:item"),
+		),
+	)
+
+	assertURL := func(expected string, expectedErr error, name string, params ...string) {
+		t.Helper()
+		actual, err := router.URL(name, params...)
+		Assert(t).That(err).Equals(expectedErr)
+		Assert(t).That(actual).Equals(expected)
+	}
+	assertURL("/", nil, "home")
+	assertURL("/users/42/orders", nil, "orders", "id", "42")
+	assertURL("/users/a%2Fb%20c/orders", nil, "orders", "id", "a/b c")
+	assertURL("/orders/7", nil, "order", "id", "7")
+	assertURL("/files/jquery.min.js", nil, "file", "name", "jquery.min", "ext", "js")
+	assertURL("/static/css/site%C3%A9.css", nil, "static", "*", "css/siteé.css")
+	assertURL("/static/", nil, "static", "*", "")
+	assertURL("/caf%C3%A9/tea", nil, "cafe", "item", "tea")
+
+	assertURL("", ErrUnknownRouteName, "missing")
+	assertURL("", ErrMissingParam, "orders")
+	assertURL("", ErrMissingParam, "orders", "id")
+	assertURL("", ErrExtraParam, "orders", "id", "42", "other", "1")
+	assertURL("", ErrDuplicateParam, "orders", "id", "42", "id", "43")
+	assertURL("", ErrInvalidParam, "orders", "id", "")
+	assertURL("", ErrInvalidParam, "orders", "id", "..")
+	assertURL("", ErrInvalidParam, "order", "id", "seven")
+	assertURL("", ErrInvalidParam, "file", "name", "jquery", "ext", "min.js") // would route as name "jquery.min"
+	assertURL("", ErrInvalidParam, "static", "*", "../secret")
+
+	path, _ := router.URL("orders", "id", "a/b c")
+	assertRoute(t, router, "GET", path, 200, "orders", "")
+
+	_, err := New(Options.Routes(named("same", "GET", "/a"), named("same", "GET", "/b")))
+	Assert(t).That(err).Wraps(ErrDuplicateRouteName)
+}
+func TestRouteIntrospection(t *testing.T) {
+	routes := ParseRoutes("GET", "/users|/users/:id|/static/*|/docs/api/v1|/a|/b|/c|/d|/e|/f|/g", simpleHandler("get"))
+	routes = append(routes, ParseRoute("POST", "/users/:id/orders", simpleHandler("post")))
+	routes = append(routes, ParseRoute("DELETE", "/users/:id", simpleHandler("delete")))
+	routes = append(routes, ParseRoute("GET", "api.example.com/ping", simpleHandler("ping")))
+	router := RequireNew(Options.Routes(routes...), Options.Middleware(func(inner http.Handler) http.Handler { return inner }))
+
+	listed := router.Routes()
+	Assert(t).That(len(listed)).Equals(len(routes))
+	Assert(t).That(listed[11].String()).Equals("POST /users/:id/orders")
+	Assert(t).That(listed[11].Handler).Equals(simpleHandler("post")) // as given, not wrapped
+
+	var visited []string
+	router.Walk(func(route Route, info NodeInfo) {
+		visited = append(visited, fmt.Sprintf("%s|%d|%s|%s|%t|%s|%s",
+			info.Host, info.Depth, info.Kind, info.Fragment, info.Indexed, info.Methods, route))
+	})
+	Assert(t).That(visited).Equals([]string{
+		"|0|static||false|| ",
+		"|1|static|users|true|GET|GET /users",
+		"|2|variable|:id|false|GET|DELETE|GET /users/:id",
+		"|2|variable|:id|false|GET|DELETE|DELETE /users/:id",
+		"|3|static|orders|false|POST|POST /users/:id/orders",
+		"|1|static|static|true|| ",
+		"|2|wildcard|*|false|GET|GET /static/*",
+		"|1|static|docs/api/v1|true|GET|GET /docs/api/v1", // compacted into a single node
+		"|1|static|a|true|GET|GET /a",
+		"|1|static|b|true|GET|GET /b",
+		"|1|static|c|true|GET|GET /c",
+		"|1|static|d|true|GET|GET /d",
+		"|1|static|e|true|GET|GET /e",
+		"|1|static|f|true|GET|GET /f",
+		"|1|static|g|true|GET|GET /g",
+		"api.example.com|0|static||false|| ",
+		"api.example.com|1|static|ping|false|GET|GET api.example.com/ping",
+	})
+}
+func TestExport(t *testing.T) {
+	routes := ParseRoutes("GET", "/users/:id|/docs/api/v1|/a|/b|/c|/d|/e|/f|/g", simpleHandler("get"))
+	routes = append(routes, ParseRoute("DELETE", "/users/:id", http.NotFoundHandler()))
+	routes = append(routes, ParseRoute("GET", "api.example.com/files/*", simpleHandler("files")))
+	router := RequireNew(Options.Routes(routes...))
+
+	var text strings.Builder
+	Assert(t).That(Export(&text, router, ExportText)).Equals(nil)
+	lines := strings.Split(text.String(), "\n")
+	Assert(t).That(len(lines)).Equals(len(routes) + 2) // the header and the empty string after the final newline
+	Assert(t).That(lines[0]).Equals("METHODS  PATTERN                  HANDLER")
+	Assert(t).That(lines[10]).Equals("DELETE   /users/:id               http.HandlerFunc")
+	Assert(t).That(lines[11]).Equals("GET      api.example.com/files/*  httprouter.simpleHandler")
+
+	var document struct {
+		Routes []struct{ Path, Handler string }
+		Nodes  []struct {
+			Fragment string
+			Wide     bool
+			Methods  []string
+			Routes   []int
+		}
+	}
+	var encoded bytes.Buffer
+	Assert(t).That(Export(&encoded, router, ExportJSON)).Equals(nil)
+	Assert(t).That(json.Unmarshal(encoded.Bytes(), &document)).Equals(nil)
+	Assert(t).That(len(document.Routes)).Equals(len(routes))
+	Assert(t).That(document.Nodes[0].Wide).Equals(true)
+	Assert(t).That(document.Nodes[2].Fragment).Equals(":id")
+	Assert(t).That(document.Nodes[2].Methods).Equals([]string{"GET", "DELETE"})
+	Assert(t).That(document.Nodes[2].Routes).Equals([]int{0, 9})
+
+	var graph strings.Builder
+	Assert(t).That(Export(&graph, RequireNew(Options.Routes(routes[0], routes[10])), ExportDOT)).Equals(nil)
+	Assert(t).That(graph.String()).Equals(`digraph routes {
+	rankdir=LR;
+	node [shape=box, fontname=monospace];
+	subgraph cluster_0 {
+		label="any host";
+		n0 [label="/"];
+		n1 [label="/users"];
+		n0 -> n1 [style=solid];
+		n2 [label="/:id\nGET"];
+		n1 -> n2 [style=dashed];
+	}
+	subgraph cluster_3 {
+		label="api.example.com";
+		n3 [label="/"];
+		n4 [label="/files"];
+		n3 -> n4 [style=solid];
+		n5 [label="/*\nGET"];
+		n4 -> n5 [style=dotted];
+	}
+}
+`)
+
+	Assert(t).That(Export(&graph, router, ExportFormat(42))).Equals(ErrUnknownFormat)
+}
+func TestReloadableRouter(t *testing.T) {
+	monitor := &reloadMonitor{}
+	started, release := make(chan struct{}), make(chan struct{})
+	slow := http.HandlerFunc(func(response http.ResponseWriter, _ *http.Request) {
+		close(started)
+		<-release
+		_, _ = io.WriteString(response, "old")
+	})
+	router, err := NewReloadable(Options.AddRoute("GET", "/slow", slow), Options.Monitor(monitor))
+	Assert(t).That(err).Equals(nil)
+
+	inFlight := httptest.NewRecorder()
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		router.ServeHTTP(inFlight, httptest.NewRequest("GET", "/slow", nil))
+	}()
+	<-started
+
+	err = router.Reload(Options.AddRoute("GET", "/slow|/fast", simpleHandler("new")), Options.Monitor(monitor))
+	Assert(t).That(err).Equals(nil)
+	assertRoute(t, router, "GET", "/slow", 200, "new", "")
+	assertRoute(t, router, "GET", "/fast", 200, "new", "")
+
+	close(release)
+	<-finished
+	Assert(t).That(inFlight.Body.String()).Equals("old") // finished on the routes it began with
+
+	err = router.Reload(Options.AddRoute("GET", "/broken//path", simpleHandler("broken")))
+	Assert(t).That(err).Wraps(ErrMalformedPath)
+	assertRoute(t, router, "GET", "/fast", 200, "new", "")
+	Assert(t).That(len(router.Routes())).Equals(2)
+	Assert(t).That(monitor.reloads).Equals([]error{nil, err})
+}
+func TestReloadableRouterIncrementalChanges(t *testing.T) {
+	monitor := &reloadMonitor{}
+	router, _ := NewReloadable(
+		Options.AddRoute("GET", "/a/b/c|/users/:id", simpleHandler("initial")),
+		Options.AddRoute("GET", "api.example.com/ping", simpleHandler("api")),
+		Options.ImplicitHead(true),
+		Options.Monitor(monitor),
+	)
+	fragments := func() (fragments []string) {
+		router.Walk(func(route Route, info NodeInfo) {
+			fragments = append(fragments, fmt.Sprintf("%d:%s", info.Depth, info.Fragment))
+		})
+		return fragments
+	}
+	Assert(t).That(fragments()).Equals([]string{"0:", "1:a/b/c", "1:users", "2::id", "0:", "1:ping"})
+	before := router.current.Load()
+
+	Assert(t).That(router.Add(ParseRoutes("GET", "/a/b|/users/:id/orders", simpleHandler("added"))...)).Equals(nil)
+	Assert(t).That(fragments()).Equals([]string{"0:", "1:a/b", "2:c", "1:users", "2::id", "3:orders", "0:", "1:ping"})
+	assertRoute(t, router, "GET", "/a/b", 200, "added", "")
+	assertRoute(t, router, "HEAD", "/a/b", 200, "", "")
+	assertRoute(t, router, "GET", "/a/b/c", 200, "initial", "")
+	assertRoute(t, router, "GET", "/users/1/orders", 200, "added", "")
+	assertRoute(t, before, "GET", "/a/b", 404, "Not Found\n", "") // the tree it copied from is left as it was
+
+	rejected := router.Add(ParseRoute("GET", "/new", simpleHandler("new")), ParseRoute("GET", "/a/b/c", simpleHandler("again")))
+	Assert(t).That(rejected).Wraps(ErrRouteExists)
+	assertRoute(t, router, "GET", "/new", 404, "Not Found\n", "") // nothing is added if anything is rejected
+
+	Assert(t).That(router.Remove(ParseRoute("GET", "/a/b", nil))).Equals(nil)
+	Assert(t).That(fragments()).Equals([]string{"0:", "1:a/b/c", "1:users", "2::id", "3:orders", "0:", "1:ping"})
+	assertRoute(t, router, "HEAD", "/a/b", 404, "Not Found\n", "")
+	assertRoute(t, router, "GET", "/a/b/c", 200, "initial", "")
+
+	Assert(t).That(router.Remove(ParseRoute("GET", "/missing", nil))).Equals(ErrRouteNotFound)
+	Assert(t).That(router.Remove(ParseRoute("GET", "api.example.com/ping", nil))).Equals(nil)
+	Assert(t).That(fragments()).Equals([]string{"0:", "1:a/b/c", "1:users", "2::id", "3:orders"})
+	Assert(t).That(len(router.Routes())).Equals(3)
+	Assert(t).That(monitor.reloads).Equals([]error{nil, rejected, nil, ErrRouteNotFound, nil})
+}
+func TestReloadableRouterIncrementalMatchesFullBuild(t *testing.T) {
+	paths := strings.Split("/|/a|/b/c/d|/b/c/e|/c/:id|/c/:id{int}/x|/d/*|/e/f/g/h|/e/f|/f|/g|/h|/i|/j|/k/l/m|/x/", "|")
+	var routes []Route
+	for _, path := range paths {
+		routes = append(routes, ParseRoute("GET", path, simpleHandler(path)), ParseRoute("POST", path, simpleHandler(path)))
+	}
+	walk := func(router Router) (nodes []string) {
+		router.Walk(func(route Route, info NodeInfo) {
+			nodes = append(nodes, fmt.Sprintf("%d:%s:%t:%s:%s", info.Depth, info.Fragment, info.Indexed, info.Methods, route))
+		})
+		return nodes
+	}
+
+	incremental, _ := NewReloadable(Options.ImplicitHead(true))
+	for _, route := range routes {
+		Assert(t).That(incremental.Add(route)).Equals(nil)
+	}
+	Assert(t).That(walk(incremental)).Equals(walk(RequireNew(Options.Routes(routes...), Options.ImplicitHead(true))))
+
+	var remaining []Route
+	for index, route := range routes {
+		if index%3 == 0 {
+			Assert(t).That(incremental.Remove(route)).Equals(nil)
+		} else {
+			remaining = append(remaining, route)
+		}
+	}
+	Assert(t).That(walk(incremental)).Equals(walk(RequireNew(Options.Routes(remaining...), Options.ImplicitHead(true))))
+
+	wide := ParseRoutes("GET", "/w/a|/w/b|/w/c|/w/d|/w/e|/w/f|/w/g|/w/h|/w/i", simpleHandler("wide"))
+	narrowed, _ := NewReloadable(Options.Routes(wide...))
+	Assert(t).That(narrowed.Remove(wide[3:]...)).Equals(nil) // six of nine siblings, below staticIndexThreshold
+	Assert(t).That(walk(narrowed)).Equals(walk(RequireNew(Options.Routes(wide[:3]...))))
+	assertRoute(t, narrowed, "GET", "/w/b", 200, "wide", "")
+	assertRoute(t, narrowed, "GET", "/w/e", 404, "Not Found\n", "")
+}
+func TestReloadableRouterDrainsRemovedRoutes(t *testing.T) {
+	started, release := make(chan struct{}), make(chan struct{})
+	route := ParseRoute("GET", "/slow", http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		started <- struct{}{}
+		<-release
+	}))
+	router, _ := NewReloadable(Options.Routes(route))
+	go router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/slow", nil))
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	Assert(t).That(router.RemoveAndDrain(ctx, route)).Equals(context.DeadlineExceeded)
+	assertRoute(t, router, "GET", "/slow", 404, "Not Found\n", "")
+
+	Assert(t).That(router.Add(route)).Equals(nil)
+	go router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/slow", nil))
+	<-started
+	drained := make(chan error)
+	go func() { drained <- router.RemoveAndDrain(context.Background(), route) }()
+	select {
+	case <-drained:
+		t.Fatal("expected the removal to wait for the request in flight")
+	case <-time.After(20 * time.Millisecond):
+	}
+	close(release)
+	Assert(t).That(<-drained).Equals(nil)
+}
+func TestAnalyze(t *testing.T) {
+	routes := []Route{
+		ParseRoute("GET", "/users/:id", simpleHandler("user")),
+		ParseRoute("POST", "/users/*", simpleHandler("upload")),
+		ParseRoute("GET", "/files/:name{.+}", simpleHandler("file")),
+		ParseRoute("GET|HEAD", "/files/:name", simpleHandler("never")),
+		ParseRoute("DELETE", "/users/:userId", simpleHandler("delete")),
+		ParseRoute("GET", "api.example.com/users/*", simpleHandler("other host")),
+		{AllowedMethods: MethodGet, Path: "/search", Handler: simpleHandler("search"), Predicates: []Predicate{QueryPresent("q")}},
+		{AllowedMethods: MethodGet, Path: "/search", Handler: simpleHandler("never"), Predicates: []Predicate{QueryPresent("q"), HeaderEquals("X-Beta", "1")}},
+		{AllowedMethods: MethodGet, Path: "/reports", Handler: simpleHandler("v1"), Version: "1", Predicates: []Predicate{QueryPresent("q")}},
+		{AllowedMethods: MethodGet, Path: "/reports", Handler: simpleHandler("v2 beta"), Version: "2", Predicates: []Predicate{QueryPresent("q"), HeaderEquals("X-Beta", "1")}},
+		{AllowedMethods: MethodGet, Path: "/reports", Handler: simpleHandler("never"), Version: "1", Predicates: []Predicate{QueryPresent("q"), HeaderEquals("X-Audit", "1")}},
+		ParseRoute("GET", "/orders/:id{int}", simpleHandler("order")),
+		ParseRoute("GET", "/orders/:id{uuid}", simpleHandler("disjoint constraints")),
+		ParseRoute("BOGUS", "/invalid", simpleHandler("skipped")),
+	}
+
+	var findings []string
+	for _, finding := range Analyze(routes) {
+		findings = append(findings, finding.String())
+	}
+	Assert(t).That(findings).Equals([]string{
+		"method-dependent: POST /users/* (POST) with GET /users/:id",
+		"unreachable: GET|HEAD /files/:name (GET) with GET /files/:name{.+}",
+		"method-dependent: GET|HEAD /files/:name (HEAD) with GET /files/:name{.+}",
+		"method-dependent: POST /users/* (POST) with DELETE /users/:userId",
+		"unreachable: GET /search (GET) with GET /search",
+		"unreachable: GET /reports (GET) with GET /reports", // the second version 1, not version 2
+		"variable-names: DELETE /users/:userId (DELETE) with GET /users/:id",
+		"wildcard-swallows: POST /users/* (POST) with GET /users/:id, DELETE /users/:userId",
+	})
+}
+func TestRouteErrors(t *testing.T) {
+	constraint := ParseRoute("GET", "/orders/:id{[a-z}/items", simpleHandler("constraint"))
+	host := ParseRoute("GET", "API.example.com/orders", simpleHandler("host"))
+	host.Host = "API.ex ample.com"
+	duplicate := ParseRoute("GET", "/orders", simpleHandler("duplicate"))
+
+	_, err := New(Options.Routes(constraint))
+	Assert(t).That(err).Equals(&RouteError{Route: constraint, Fragment: ":id{[a-z}", Offset: 8, Err: ErrInvalidConstraint})
+	Assert(t).That(errors.Is(err, ErrInvalidConstraint)).Equals(true)
+	Assert(t).That(err.Error()).Equals(`GET /orders/:id{[a-z}/items: ` + ErrInvalidConstraint.Error() + ` (":id{[a-z}" at offset 8)`)
+
+	_, err = New(Options.Routes(host))
+	Assert(t).That(err).Equals(&RouteError{Route: host, Fragment: "ex ample", Offset: 4, Err: ErrMalformedHost})
+
+	routes := Options.Routes(duplicate, constraint, host, ParseRoute("GET", "/valid", simpleHandler("valid")), duplicate)
+	_, err = New(routes)
+	Assert(t).That(err).Wraps(ErrInvalidConstraint) // the first error only
+
+	_, err = New(routes, Options.JoinErrors(true))
+	Assert(t).That(errors.Is(err, ErrMalformedHost)).Equals(true)
+	var rejected []error
+	for _, routeError := range err.(interface{ Unwrap() []error }).Unwrap() {
+		rejected = append(rejected, routeError.(*RouteError).Err)
+	}
+	Assert(t).That(rejected).Equals([]error{ErrInvalidConstraint, ErrMalformedHost, ErrRouteExists})
+	Assert(t).That(err.(interface{ Unwrap() []error }).Unwrap()[2]).Equals(&RouteError{Route: duplicate, Offset: -1, Err: ErrRouteExists})
+
+	router, _ := NewReloadable(Options.JoinErrors(true))
+	err = router.Add(constraint, duplicate, duplicate)
+	Assert(t).That(len(err.(interface{ Unwrap() []error }).Unwrap())).Equals(2)
+	Assert(t).That(len(router.Routes())).Equals(0)
+}
+func assertRedirect(t *testing.T, router http.Handler, method, path string, expectedStatus int, expectedLocation string) {
+	t.Helper()
+	t.Run(fmt.Sprintf("%s:%s:%d", method, path, expectedStatus), func(t *testing.T) {
+		t.Helper()
+
+		request := httptest.NewRequest(method, path+"?query=value", nil)
+		recorder := httptest.NewRecorder()
+
+		router.ServeHTTP(recorder, request)
+
+		if recorder.Code != expectedStatus {
+			t.Errorf("expected status [%d], actual status: [%d] for test [%s %s]", expectedStatus, recorder.Code, method, path)
+		}
+		if actualLocation := recorder.Header().Get("Location"); actualLocation != expectedLocation {
+			t.Errorf("expected Location [%s], actual Location: [%s] for test [%s %s]", expectedLocation, actualLocation, method, path)
+		}
+	})
+}
+
 func TestFallbackToURL(t *testing.T) {
 	router := RequireNew(Options.AddRoute("GET", "/", simpleHandler(t.Name())))
 	request := httptest.NewRequest("GET", "/", nil)
@@ -272,7 +1473,7 @@ func assertRouteAlreadyExists(t *testing.T, method string) {
 		_, err1 := addRouteWithError(tree, method, "/stuff")
 		_, err2 := addRouteWithError(tree, method, "/stuff")
 		Assert(t).That(err1).IsNil()
-		Assert(t).That(err2).Equals(ErrRouteExists)
+		Assert(t).That(err2).Wraps(ErrRouteExists)
 	})
 }
 func TestRouteAlreadyExists_NoPartialRegistration(t *testing.T) {
@@ -282,7 +1483,7 @@ func TestRouteAlreadyExists_NoPartialRegistration(t *testing.T) {
 	_ = tree.Add(Route{AllowedMethods: MethodPost, Path: "/stuff", Handler: handler})
 	err := tree.Add(Route{AllowedMethods: MethodGet | MethodPost, Path: "/stuff", Handler: handler})
 
-	Assert(t).That(err).Equals(ErrRouteExists)
+	Assert(t).That(err).Wraps(ErrRouteExists)
 
 	resolved, _ := tree.Resolve("GET", "/stuff")
 	if resolved != nil {
@@ -293,7 +1494,7 @@ func TestNilRouteHandler(t *testing.T) {
 	tree := &treeNode{}
 	route := Route{AllowedMethods: MethodGet, Path: "/stuff"}
 
-	Assert(t).That(tree.Add(route)).Equals(ErrNilHandler)
+	Assert(t).That(tree.Add(route)).Wraps(ErrNilHandler)
 
 	route.Handler = simpleHandler(t.Name())
 	Assert(t).That(tree.Add(route)).IsNil()
@@ -304,33 +1505,65 @@ func TestNilRouteHandler(t *testing.T) {
 	}
 
 	_, err := New(Options.AddRoute("GET", "/stuff", nil))
-	Assert(t).That(err).Equals(ErrNilHandler)
+	Assert(t).That(err).Wraps(ErrNilHandler)
 }
-func TestNonASCIICharactersRejected(t *testing.T) {
+func TestPercentEncodedRegistration(t *testing.T) {
 	tree := &treeNode{}
 	_, err1 := addRouteWithError(tree, "GET", "/café")
-	_, err2 := addRouteWithError(tree, "GET", "/日本語")
-	_, err3 := addRouteWithError(tree, "GET", "/path/über")
-	Assert(t).That(err1).Equals(ErrInvalidCharacters)
-	Assert(t).That(err2).Equals(ErrInvalidCharacters)
-	Assert(t).That(err3).Equals(ErrInvalidCharacters)
+	_, err2 := addRouteWithError(tree, "GET", "/caf%C3%A9")
+	_, err3 := addRouteWithError(tree, "GET", "/caf%c3%a9")
+	_, err4 := addRouteWithError(tree, "GET", "/%7Euser")
+	_, err5 := addRouteWithError(tree, "GET", "/~user")
+	_, err6 := addRouteWithError(tree, "GET", "/caf%C3")
+	_, err7 := addRouteWithError(tree, "GET", "/caf%G1")
+	_, err8 := addRouteWithError(tree, "GET", "/with\"quote")
+	_, err9 := addRouteWithError(tree, "GET", "/caf%")
+	Assert(t).That(err1).IsNil()
+	Assert(t).That(err2).Wraps(ErrRouteExists)
+	Assert(t).That(err3).Wraps(ErrRouteExists)
+	Assert(t).That(err4).IsNil()
+	Assert(t).That(err5).Wraps(ErrRouteExists)
+	Assert(t).That(err6).IsNil()
+	Assert(t).That(err7).Wraps(ErrInvalidCharacters)
+	Assert(t).That(err8).Wraps(ErrInvalidCharacters)
+	Assert(t).That(err9).Wraps(ErrInvalidCharacters)
+}
+func TestPathCharacters(t *testing.T) {
+	router := RequireNew(Options.Routes(
+		ParseRoute("GET", "/users/@:handle", paramsHandler{"handle"}),
+		ParseRoute("GET", "/~admin/a+b,c;d=e!$&'()", simpleHandler("sub-delims")),
+		ParseRoute("GET", "/日本語/:page", paramsHandler{"page"}),
+		ParseRoute("GET", "/time/12%3A00", simpleHandler("noon")),
+		ParseRoute("GET", "/files/:name", paramsHandler{"name"}),
+	))
+
+	assertRoute(t, router, "GET", "/users/@gopher", 200, "handle=gopher", "")
+	assertRoute(t, router, "GET", "/~admin/a+b,c;d=e!$&'()", 200, "sub-delims", "")
+	assertRoute(t, router, "GET", "/%7eadmin/a+b,c;d=e!$&'()", 200, "sub-delims", "")
+	assertRoute(t, router, "GET", "/日本語/1", 200, "page=1", "")
+	assertRoute(t, router, "GET", "/%E6%97%A5%E6%9C%AC%E8%AA%9E/1", 200, "page=1", "")
+	assertRoute(t, router, "GET", "/%e6%97%a5%e6%9c%ac%e8%aa%9e/1", 200, "page=1", "")
+	assertRoute(t, router, "GET", "/time/12%3a00", 200, "noon", "")
+	assertRoute(t, router, "GET", "/files/caf%c3%a9", 200, "name=caf%C3%A9", "") // values keep their (canonical) encoding
+	assertRoute(t, router, "GET", "/files/a%2Fb", 200, "name=a%2Fb", "")         // an encoded slash is not a separator
+	assertRoute(t, router, "GET", "/files/%41", 200, "name=A", "")
 }
 func TestMalformedRouteRegistration(t *testing.T) {
 	tree := &treeNode{}
 	_, err1 := addRouteWithError(tree, "GET", "//stuff")
 	_, err2 := addRouteWithError(tree, "GET", "/stu*ff")
-	_, err3 := addRouteWithError(tree, "GET", "/stu:ff")
+	_, err3 := addRouteWithError(tree, "GET", "/stu:ff*") // "stu:ff" alone is a mid-segment variable
 	_, err4 := addRouteWithError(tree, "GET", "/stuff//identities")
 	_, err5 := addRouteWithError(tree, "GET", "/stuff/*more_stuff")
 	_, err6 := addRouteWithError(tree, "GET", "stuff")
 	_, err7 := addRouteWithError(tree, "BAD-METHOD", "/")
-	Assert(t).That(err1).Equals(ErrMalformedPath)
-	Assert(t).That(err2).Equals(ErrInvalidCharacters)
-	Assert(t).That(err3).Equals(ErrInvalidCharacters)
-	Assert(t).That(err4).Equals(ErrMalformedPath)
-	Assert(t).That(err5).Equals(ErrInvalidWildcard)
-	Assert(t).That(err6).Equals(ErrMalformedPath)
-	Assert(t).That(err7).Equals(ErrUnknownMethod)
+	Assert(t).That(err1).Wraps(ErrMalformedPath)
+	Assert(t).That(err2).Wraps(ErrInvalidCharacters)
+	Assert(t).That(err3).Wraps(ErrInvalidCharacters)
+	Assert(t).That(err4).Wraps(ErrMalformedPath)
+	Assert(t).That(err5).Wraps(ErrInvalidWildcard)
+	Assert(t).That(err6).Wraps(ErrMalformedPath)
+	Assert(t).That(err7).Wraps(ErrUnknownMethod)
 }
 
 func TestUnknownMethodDoesNotPartiallyRegisterRoute(t *testing.T) {
@@ -338,20 +1571,20 @@ func TestUnknownMethodDoesNotPartiallyRegisterRoute(t *testing.T) {
 	handler := simpleHandler(t.Name())
 
 	err := tree.Add(ParseRoute("GET|BAD-METHOD", "/stuff", handler))
-	Assert(t).That(err).Equals(ErrUnknownMethod)
+	Assert(t).That(err).Wraps(ErrUnknownMethod)
 
 	err = tree.Add(Route{
 		AllowedMethods: MethodGet | Method(1<<15),
 		Path:           "/manual-mask",
 		Handler:        handler,
 	})
-	Assert(t).That(err).Equals(ErrUnknownMethod)
+	Assert(t).That(err).Wraps(ErrUnknownMethod)
 
 	err = tree.Add(Route{
 		Path:    "/zero-mask",
 		Handler: handler,
 	})
-	Assert(t).That(err).Equals(ErrUnknownMethod)
+	Assert(t).That(err).Wraps(ErrUnknownMethod)
 
 	resolved, allowed := tree.Resolve("GET", "/stuff")
 	if resolved != nil || allowed != 0 {
@@ -359,10 +1592,10 @@ func TestUnknownMethodDoesNotPartiallyRegisterRoute(t *testing.T) {
 	}
 
 	_, err = New(Options.AddRoute("GET|BAD-METHOD", "/stuff", handler))
-	Assert(t).That(err).Equals(ErrUnknownMethod)
+	Assert(t).That(err).Wraps(ErrUnknownMethod)
 
 	_, err = New(Options.AddRoute("GET||POST", "/stuff", handler))
-	Assert(t).That(err).Equals(ErrUnknownMethod)
+	Assert(t).That(err).Wraps(ErrUnknownMethod)
 }
 
 func addRoute(tree *treeNode, method, path string) fakeHandler {
@@ -407,6 +1640,64 @@ func (this simpleHandler) ServeHTTP(response http.ResponseWriter, _ *http.Reques
 	}
 }
 
+// patternHandler writes the pattern the router recorded on http.Request.Pattern.
+type patternHandler struct{}
+
+func (patternHandler) ServeHTTP(response http.ResponseWriter, request *http.Request) {
+	_, _ = io.WriteString(response, request.Pattern)
+}
+
+type recordingMonitor struct {
+	nop
+	routed           []string
+	notFound         int
+	methodNotAllowed int
+	rejected         []int
+}
+
+func (this *recordingMonitor) RoutedTo(_ *http.Request, route Route) {
+	this.routed = append(this.routed, route.String())
+}
+func (this *recordingMonitor) NotFound(*http.Request)         { this.notFound++ }
+func (this *recordingMonitor) MethodNotAllowed(*http.Request) { this.methodNotAllowed++ }
+func (this *recordingMonitor) Rejected(_ *http.Request, status int) {
+	this.rejected = append(this.rejected, status)
+}
+
+// restoreMethodRegistry puts the process-wide method registry back as it was once the test ends, so the methods a
+// test registers don't change the outcome of the tests run after it.
+func restoreMethodRegistry(t *testing.T) {
+	methodRegistry.Lock()
+	ordered, registered := append([]Method(nil), orderedMethods...), registeredMethods
+	values, available := maps.Clone(methodValues), maps.Clone(availableMethods)
+	methodRegistry.Unlock()
+
+	t.Cleanup(func() {
+		methodRegistry.Lock()
+		defer methodRegistry.Unlock()
+		orderedMethods, registeredMethods, methodValues, availableMethods = ordered, registered, values, available
+		customHeaderValues.Clear()
+	})
+}
+
+type reloadMonitor struct {
+	nop
+	reloads []error
+}
+
+func (this *reloadMonitor) Reloaded(err error) { this.reloads = append(this.reloads, err) }
+
+// paramsHandler writes "name=value" for each of its names, read through http.Request.PathValue.
+type paramsHandler []string
+
+func (this paramsHandler) ServeHTTP(response http.ResponseWriter, request *http.Request) {
+	values := make([]string, 0, len(this))
+	for _, name := range this {
+		values = append(values, name+"="+request.PathValue(name))
+	}
+	_, _ = io.WriteString(response, strings.Join(values, ","))
+}
+
 ////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
 
 func BenchmarkTreeStatic(b *testing.B) {
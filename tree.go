@@ -9,9 +9,11 @@ type treeNode struct {
 	pathFragment string
 	static       []*treeNode
 	staticIndex  map[string]*treeNode // populated only once static children exceed staticIndexThreshold; nil for narrow nodes
-	variable     *treeNode
+	variables    []*treeNode          // alternatives for one whole segment, in the order Resolve tries them
 	wildcard     *treeNode
 	handlers     *methodHandlers
+	matcher      *segmentMatcher // restricts the segments a variable node accepts; nil accepts any
+	beneath      Method          // the methods allowed at this node or anywhere beneath it; see tally
 }
 
 // staticIndexThreshold is the number of static children beyond which a node maintains a map for O(1) lookups
@@ -20,86 +22,105 @@ type treeNode struct {
 const staticIndexThreshold = 8
 
 func (this *treeNode) Add(route Route) error {
-	if route.AllowedMethods == 0 ||
-		route.AllowedMethods&MethodNone != 0 ||
-		route.AllowedMethods&^supportedMethods != 0 {
-		return ErrUnknownMethod
-	}
-	if route.Handler == nil {
-		return ErrNilHandler
+	return this.add(route, route.Handler)
+}
+
+// add registers route to be served by handler, which is the route's own Handler wrapped by any middleware; the
+// endpoint keeps the route as it was given.
+func (this *treeNode) add(route Route, handler http.Handler) error {
+	// The whole route is validated before any node is created, so a rejected route never leaves a partial branch.
+	endpoint, err := newRouteEndpoint(route, handler)
+	if err != nil {
+		return err
 	}
 
-	if len(route.Path) == 0 {
-		if this.handlers == nil {
-			this.handlers = &methodHandlers{}
+	node := this
+	for _, segment := range endpoint.template.segments {
+		switch segment.kind {
+		case segmentWildcard:
+			node = node.addWildcard(segment.text)
+		case segmentVariable:
+			node = node.addVariable(segment.text, segment.matcher)
+		default:
+			node = node.addStatic(segment.text)
 		}
+	}
 
-		return this.handlers.Add(route.AllowedMethods, route.Handler)
+	if node.handlers == nil {
+		node.handlers = &methodHandlers{}
 	}
 
-	if route.Path[0] == '/' {
-		route.Path = route.Path[1:]
-	} else {
-		return ErrMalformedPath
+	if err := node.handlers.Add(route.AllowedMethods, endpoint); err != nil {
+		return newRouteError(route, err)
 	}
+	return nil
+}
 
-	var pathFragmentForChildNode string
-	slashIndex := strings.IndexByte(route.Path, '/')
-	if slashIndex == 0 {
-		return ErrMalformedPath // first character is a slash, that means the URL provided looks something like this: /path/to//document (note the double slash)
-	} else if slashIndex == -1 {
-		pathFragmentForChildNode = route.Path
-	} else {
-		pathFragmentForChildNode = route.Path[0:slashIndex]
+// newRouteEndpoint validates route and returns the endpoint that serves it with handler, or a *RouteError.
+func newRouteEndpoint(route Route, handler http.Handler) (*endpoint, error) {
+	if route.AllowedMethods == 0 ||
+		route.AllowedMethods&MethodNone != 0 ||
+		route.AllowedMethods&^(supportedMethods|registeredMethodsSnapshot()|MethodAny) != 0 {
+		return nil, newRouteError(route, ErrUnknownMethod)
+	}
+	if handler == nil {
+		return nil, newRouteError(route, ErrNilHandler)
 	}
 
-	if !hasOnlyAllowedCharacters(pathFragmentForChildNode) {
-		return ErrInvalidCharacters
-	} else if strings.HasPrefix(pathFragmentForChildNode, "*") {
-		return this.addWildcard(route, pathFragmentForChildNode)
-	} else if strings.HasPrefix(pathFragmentForChildNode, ":") {
-		return this.addVariable(route, pathFragmentForChildNode)
-	} else {
-		return this.addStatic(route, pathFragmentForChildNode)
+	template, err := parsePathTemplate(route.Path)
+	if err != nil {
+		return nil, newRouteError(route, err)
 	}
+	predicateKey, valid := newCandidateKey(route.Predicates)
+	if !valid {
+		return nil, newRouteError(route, ErrInvalidPredicate)
+	}
+	return newEndpoint(route, template, predicateKey, handler), nil
 }
-func (this *treeNode) addWildcard(route Route, pathFragment string) error {
+func (this *treeNode) addWildcard(pathFragment string) *treeNode {
 	if this.wildcard == nil {
 		this.wildcard = &treeNode{pathFragment: pathFragment}
 	}
 
-	if len(route.Path) > 1 {
-		return ErrInvalidWildcard // must only be "*"
-	}
-
-	route.Path = ""
-	return this.wildcard.Add(route)
+	return this.wildcard
 }
-func (this *treeNode) addVariable(route Route, pathFragment string) error {
-	if this.variable == nil {
-		this.variable = &treeNode{pathFragment: pathFragment}
+
+// addVariable returns the variable child for the matcher, creating it if needed. Variables are identified by what
+// they accept (literals and constraints) alone, so routes spelling different names at the same position share a
+// node; each endpoint keeps its own names. Children are kept in the order Resolve tries them: segments with more
+// literal text first, then constrained variables, then the plain ":name"; equal ranks keep registration order.
+func (this *treeNode) addVariable(pathFragment string, matcher *segmentMatcher) *treeNode {
+	for _, variableChild := range this.variables {
+		if variableChild.matcher.equals(matcher) {
+			return variableChild
+		}
 	}
 
-	route.Path = route.Path[len(pathFragment):]
-	return this.variable.Add(route)
+	variableChild := &treeNode{pathFragment: pathFragment, matcher: matcher}
+	position := len(this.variables)
+	for index, existingChild := range this.variables {
+		if matcher.outranks(existingChild.matcher) {
+			position = index
+			break
+		}
+	}
+	this.variables = append(this.variables[:position], append([]*treeNode{variableChild}, this.variables[position:]...)...)
+	return variableChild
 }
-func (this *treeNode) addStatic(route Route, pathFragment string) (err error) {
-	route.Path = route.Path[len(pathFragment):]
-
+func (this *treeNode) accepts(segment string, fold bool) bool {
+	return this.matcher == nil || this.matcher.matches(segment, fold)
+}
+func (this *treeNode) addStatic(pathFragment string) *treeNode {
 	for _, staticChild := range this.static {
 		if staticChild.pathFragment == pathFragment {
-			return staticChild.Add(route)
+			return staticChild
 		}
 	}
 
 	staticChild := &treeNode{pathFragment: pathFragment}
-	if err = staticChild.Add(route); err != nil {
-		return err
-	}
-
 	this.static = append(this.static, staticChild)
 	this.indexStatic(staticChild)
-	return nil
+	return staticChild
 }
 
 // indexStatic maintains the staticIndex map for wide nodes. Once the child count crosses staticIndexThreshold the
@@ -122,18 +143,18 @@ func (this *treeNode) indexStatic(staticChild *treeNode) {
 }
 
 // compact collapses chains of single-child static nodes into multi-segment fragments, turning the per-segment
-// trie into a compressed radix tree. It is a one-time pass run after all routes are registered — the tree is
-// immutable thereafter — so no edge-splitting is ever needed. It descends the whole tree but never extends a
-// node's own fragment; only a node's static children (genuine matched literals) are absorbed, via absorbChain.
-// This is why it is safe to call on the root, and on variable/wildcard nodes, whose own fragments are consumed
-// positionally rather than matched literally and so must never grow.
+// trie into a compressed radix tree. It is a one-time pass run after all routes are registered, so registration
+// never needs to split an edge (only the incremental updates in update.go do, on copies). It descends the whole
+// tree but never extends a node's own fragment; only a node's static children (genuine matched literals) are
+// absorbed, via absorbChain. This is why it is safe to call on the root, and on variable/wildcard nodes, whose own
+// fragments are consumed positionally rather than matched literally and so must never grow.
 func (this *treeNode) compact() {
 	for _, staticChild := range this.static {
 		staticChild.absorbChain()
 		staticChild.compact()
 	}
-	if this.variable != nil {
-		this.variable.compact()
+	for _, variableChild := range this.variables {
+		variableChild.compact()
 	}
 	if this.wildcard != nil {
 		this.wildcard.compact()
@@ -148,7 +169,7 @@ func (this *treeNode) compact() {
 // map key) or its identity. The empty-fragment trailing-slash leaf is left alone — absorbing it would only
 // splice a '/' onto the fragment for no benefit.
 func (this *treeNode) absorbChain() {
-	for len(this.static) == 1 && this.variable == nil && this.wildcard == nil && this.handlers == nil {
+	for len(this.static) == 1 && len(this.variables) == 0 && this.wildcard == nil && this.handlers == nil {
 		onlyChild := this.static[0]
 		if onlyChild.pathFragment == "" {
 			return
@@ -156,23 +177,76 @@ func (this *treeNode) absorbChain() {
 		this.pathFragment += "/" + onlyChild.pathFragment
 		this.static = onlyChild.static
 		this.staticIndex = onlyChild.staticIndex
-		this.variable = onlyChild.variable
+		this.variables = onlyChild.variables
 		this.wildcard = onlyChild.wildcard
 		this.handlers = onlyChild.handlers
+		this.beneath = onlyChild.beneath
 	}
 }
-func hasOnlyAllowedCharacters(input string) bool {
-	for index, value := range input {
-		if _, ok := allowedCharacters[value]; ok {
-			continue
-		} else if index == 0 && (value == '*' || value == ':') {
-			continue
-		}
 
-		return false
+// finalize prepares the tree for routing once every route has been added. A tree is never changed once it routes
+// requests (ReloadableRouter changes copies of the nodes affected; see update.go), so single-child static chains
+// are collapsed into multi-segment nodes once here, letting Resolve settle a non-branching path in one comparison
+// instead of one recursive frame per segment.
+func (this *treeNode) finalize(implicitHead bool) {
+	this.compact()
+	if implicitHead {
+		this.implyHead()
 	}
+	this.tallyAll()
+}
 
-	return true
+// implyHead lets every node beneath this one that serves GET but not HEAD serve HEAD through its GET handler, with the
+// response body discarded. Like compact, it runs once after registration; an explicit HEAD route is left as it is.
+func (this *treeNode) implyHead() {
+	if this.handlers != nil {
+		this.handlers.implyHead()
+	}
+	for _, staticChild := range this.static {
+		staticChild.implyHead()
+	}
+	for _, variableChild := range this.variables {
+		variableChild.implyHead()
+	}
+	if this.wildcard != nil {
+		this.wildcard.implyHead()
+	}
+}
+
+// methods returns the union of the methods allowed at this node or anywhere beneath it, as last tallied.
+func (this *treeNode) methods() Method {
+	return this.beneath
+}
+
+// tally records the methods allowed at this node or beneath it from its own handlers and what its children have
+// recorded, so it must run after the children's. finalize tallies every node once, deepest first (tallyAll); an
+// incremental update tallies only the copies on the path it changed, as every other node's tally still holds.
+func (this *treeNode) tally() {
+	this.beneath = 0
+	if this.handlers != nil {
+		this.beneath = this.handlers.allowed
+	}
+	for _, staticChild := range this.static {
+		this.beneath |= staticChild.beneath
+	}
+	for _, variableChild := range this.variables {
+		this.beneath |= variableChild.beneath
+	}
+	if this.wildcard != nil {
+		this.beneath |= this.wildcard.beneath
+	}
+}
+func (this *treeNode) tallyAll() {
+	for _, staticChild := range this.static {
+		staticChild.tallyAll()
+	}
+	for _, variableChild := range this.variables {
+		variableChild.tallyAll()
+	}
+	if this.wildcard != nil {
+		this.wildcard.tallyAll()
+	}
+	this.tally()
 }
 
 // Resolve walks the tree iteratively. Whenever a node's only viable continuation is a single deterministic edge —
@@ -180,7 +254,17 @@ func hasOnlyAllowedCharacters(input string) bool {
 // wildcard — the walk reassigns the receiver and loops instead of recursing, so a non-branching path costs no
 // stack frames at all. Recursion is kept only where a node genuinely has an alternative to try if the
 // higher-priority edge fails to resolve the requested method.
-func (this *treeNode) Resolve(method, incomingPath string) (http.Handler, Method) {
+func (this *treeNode) Resolve(method, incomingPath string) (*endpoint, Method) {
+	return this.resolve(method, incomingPath, false)
+}
+
+// ResolveFold is Resolve with static text (and the literal text of variable segments) compared without regard to
+// ASCII case. It is only consulted after Resolve has found nothing, as it gives up the staticIndex map: its keys are
+// exact-case, so wide nodes are scanned linearly instead.
+func (this *treeNode) ResolveFold(method, incomingPath string) (*endpoint, Method) {
+	return this.resolve(method, incomingPath, true)
+}
+func (this *treeNode) resolve(method, incomingPath string, fold bool) (*endpoint, Method) {
 	for {
 		if len(incomingPath) == 0 {
 			if this.handlers == nil {
@@ -195,7 +279,7 @@ func (this *treeNode) Resolve(method, incomingPath string) (http.Handler, Method
 
 		var matchedStatic *treeNode
 
-		if len(this.static) >= staticIndexThreshold {
+		if len(this.static) >= staticIndexThreshold && !fold {
 			// Wide node: probe the map by the incoming path's first segment (the map key), then match the
 			// candidate child's full fragment — which may span several segments once the compaction pass has
 			// merged a single-child chain into it.
@@ -225,10 +309,13 @@ func (this *treeNode) Resolve(method, incomingPath string) (http.Handler, Method
 				if len(incomingPath) < fragmentLength {
 					continue
 				}
-				if fragmentLength > 0 && incomingPath[0] != staticChild.pathFragment[0] {
+				if fold {
+					if !equalFoldASCII(incomingPath[:fragmentLength], staticChild.pathFragment) {
+						continue
+					}
+				} else if fragmentLength > 0 && incomingPath[0] != staticChild.pathFragment[0] {
 					continue // cheap first-byte reject before the full compare (empty fragment: trailing-slash child)
-				}
-				if incomingPath[:fragmentLength] != staticChild.pathFragment {
+				} else if incomingPath[:fragmentLength] != staticChild.pathFragment {
 					continue
 				}
 				if fragmentLength != len(incomingPath) && incomingPath[fragmentLength] != '/' {
@@ -244,141 +331,144 @@ func (this *treeNode) Resolve(method, incomingPath string) (http.Handler, Method
 
 		if matchedStatic != nil {
 			remainingPath := incomingPath[len(matchedStatic.pathFragment):]
-			if this.variable == nil && this.wildcard == nil {
+			if len(this.variables) == 0 && this.wildcard == nil {
 				this = matchedStatic
 				incomingPath = remainingPath
 				continue
 			}
-			handler, allowed := matchedStatic.Resolve(method, remainingPath)
-			if handler != nil {
-				return handler, MethodNone
+			resolved, allowed := matchedStatic.resolve(method, remainingPath, fold)
+			if resolved != nil {
+				return resolved, MethodNone
 			}
 			staticAllowed = allowed
 		}
 
-		if this.variable != nil && len(incomingPath) > 0 && incomingPath[0] != '/' {
+		if len(this.variables) > 0 && len(incomingPath) > 0 && incomingPath[0] != '/' {
 			// A variable consumes exactly one segment, so the boundary is needed here.
-			var remainingPath string
+			segment, remainingPath := incomingPath, ""
 			if slash := strings.IndexByte(incomingPath, '/'); slash >= 0 {
-				remainingPath = incomingPath[slash:]
+				segment, remainingPath = incomingPath[:slash], incomingPath[slash:]
 			}
-			if matchedStatic == nil && this.wildcard == nil {
-				this = this.variable
+			if matchedStatic == nil && this.wildcard == nil && len(this.variables) == 1 {
+				if !this.variables[0].accepts(segment, fold) {
+					return nil, 0 // the lone variable rejects the segment and there is nothing to fall back to
+				}
+				this = this.variables[0]
 				incomingPath = remainingPath
 				continue
 			}
-			handler, allowed := this.variable.Resolve(method, remainingPath)
-			if handler != nil {
-				return handler, MethodNone
+			// Each variable whose constraint accepts the segment is a candidate; the first to resolve the
+			// method wins, and the others contribute what they allow in case none does.
+			for _, variableChild := range this.variables {
+				if !variableChild.accepts(segment, fold) {
+					continue
+				}
+				resolved, allowed := variableChild.resolve(method, remainingPath, fold)
+				if resolved != nil {
+					return resolved, MethodNone
+				}
+				variableAllowed |= allowed
 			}
-			variableAllowed = allowed
 		}
 
 		if this.wildcard != nil {
-			if matchedStatic == nil && this.variable == nil {
+			if staticAllowed|variableAllowed == 0 {
 				this = this.wildcard
 				incomingPath = ""
 				continue
 			}
-			handler, wildcardAllowed := this.wildcard.Resolve(method, "")
-			return handler, staticAllowed | variableAllowed | wildcardAllowed
+			resolved, wildcardAllowed := this.wildcard.resolve(method, "", fold)
+			return resolved, staticAllowed | variableAllowed | wildcardAllowed
 		}
 
 		return nil, staticAllowed | variableAllowed
 	}
 }
 
-var allowedCharacters = map[rune]struct{}{
-	// lower a-z
-	'a': {}, 'b': {}, 'c': {}, 'd': {}, 'e': {}, 'f': {}, 'g': {},
-	'h': {}, 'i': {}, 'j': {}, 'k': {}, 'l': {}, 'm': {}, 'n': {},
-	'o': {}, 'p': {}, 'q': {}, 'r': {}, 's': {}, 't': {}, 'u': {},
-	'v': {}, 'w': {}, 'x': {}, 'y': {}, 'z': {},
-	// upper A-Z
-	'A': {}, 'B': {}, 'C': {}, 'D': {}, 'E': {}, 'F': {}, 'G': {},
-	'H': {}, 'I': {}, 'J': {}, 'K': {}, 'L': {}, 'M': {}, 'N': {},
-	'O': {}, 'P': {}, 'Q': {}, 'R': {}, 'S': {}, 'T': {}, 'U': {},
-	'V': {}, 'W': {}, 'X': {}, 'Y': {}, 'Z': {},
-	// 0-9
-	'0': {}, '1': {}, '2': {}, '3': {}, '4': {},
-	'5': {}, '6': {}, '7': {}, '8': {}, '9': {},
-	// other characters
-	'.': {}, '-': {}, '_': {},
+// equalFoldASCII reports whether two strings of equal length are equal ignoring the case of ASCII letters, the only
+// letters a route path may spell.
+func equalFoldASCII(left, right string) bool {
+	if len(left) != len(right) {
+		return false
+	}
+	for index := 0; index < len(left); index++ {
+		if left[index] != right[index] && toLowerASCII(left[index]) != toLowerASCII(right[index]) {
+			return false
+		}
+	}
+	return true
+}
+func toLowerASCII(value byte) byte {
+	if value >= 'A' && value <= 'Z' {
+		return value + ('a' - 'A')
+	}
+	return value
 }
 
 ////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
 
+// methodHandlers holds a node's endpoints indexed by method bit (see methodIndex), plus the catch-all endpoint of a
+// MethodAny route, which serves any method without one of its own.
 type methodHandlers struct {
-	allowed Method
-	Get     http.Handler
-	Head    http.Handler
-	Post    http.Handler
-	Put     http.Handler
-	Delete  http.Handler
-	Connect http.Handler
-	Options http.Handler
-	Trace   http.Handler
-	Patch   http.Handler
+	allowed   Method
+	endpoints []*endpoint
+	any       *endpoint
 }
 
-func (this *methodHandlers) Add(allowed Method, handler http.Handler) error {
-	if this.allowed&allowed&^MethodNone != 0 {
-		return ErrRouteExists
+func (this *methodHandlers) Add(allowed Method, handler *endpoint) error {
+	// a method may only be registered again with a version or predicates that tell it apart
+	for index := 1; index < 64; index++ {
+		if method := Method(1) << index; allowed&method == method {
+			if existing := this.get(method); existing != nil && !existing.admits(handler) {
+				return ErrRouteExists
+			}
+		}
 	}
 
-	// allow handler to be registered multiple times
-	if allowed&MethodGet == MethodGet {
-		this.Get = handler
-	}
-	if allowed&MethodHead == MethodHead {
-		this.Head = handler
-	}
-	if allowed&MethodPost == MethodPost {
-		this.Post = handler
-	}
-	if allowed&MethodPut == MethodPut {
-		this.Put = handler
-	}
-	if allowed&MethodDelete == MethodDelete {
-		this.Delete = handler
-	}
-	if allowed&MethodConnect == MethodConnect {
-		this.Connect = handler
+	// allow handler to be registered multiple times; each method gets its own endpoint to carry its own pattern
+	for index := 1; index < 64; index++ {
+		method := Method(1) << index
+		if allowed&method != method {
+			continue
+		} else if existing := this.get(method); existing != nil {
+			existing.chain(handler.forMethod(method))
+		} else {
+			this.put(method, handler.forMethod(method))
+		}
 	}
-	if allowed&MethodOptions == MethodOptions {
-		this.Options = handler
+
+	this.allowed |= allowed
+	return nil
+}
+func (this *methodHandlers) implyHead() {
+	if this.allowed&(MethodGet|MethodHead) == MethodGet {
+		this.put(MethodHead, this.get(MethodGet).forHead())
+		this.allowed |= MethodHead
 	}
-	if allowed&MethodTrace == MethodTrace {
-		this.Trace = handler
+}
+func (this *methodHandlers) get(method Method) *endpoint {
+	if method == MethodAny {
+		return this.any
+	} else if index := bitIndex(method); index < len(this.endpoints) {
+		return this.endpoints[index]
 	}
-	if allowed&MethodPatch == MethodPatch {
-		this.Patch = handler
+	return nil
+}
+func (this *methodHandlers) put(method Method, handler *endpoint) {
+	if method == MethodAny {
+		this.any = handler
+		return
 	}
 
-	this.allowed |= allowed
-	return nil
+	index := bitIndex(method)
+	for len(this.endpoints) <= index {
+		this.endpoints = append(this.endpoints, nil)
+	}
+	this.endpoints[index] = handler
 }
-func (this *methodHandlers) Resolve(method string) http.Handler {
-	switch method {
-	case http.MethodGet:
-		return this.Get
-	case http.MethodHead:
-		return this.Head
-	case http.MethodPost:
-		return this.Post
-	case http.MethodPut:
-		return this.Put
-	case http.MethodDelete:
-		return this.Delete
-	case http.MethodConnect:
-		return this.Connect
-	case http.MethodOptions:
-		return this.Options
-	case http.MethodTrace:
-		return this.Trace
-	case http.MethodPatch:
-		return this.Patch
-	default:
-		return nil
+func (this *methodHandlers) Resolve(method string) *endpoint {
+	if index, known := methodIndex(method); known && index < len(this.endpoints) && this.endpoints[index] != nil {
+		return this.endpoints[index]
 	}
+	return this.any
 }
@@ -0,0 +1,352 @@
+package httprouter
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Incremental updates (ReloadableRouter.Add and Remove) never change a tree that may be routing requests. Instead
+// they copy each node on the path from the root to the node the route ends at, change the copies, and share every
+// other node with the tree they were copied from; the copies take the place of the originals all at once, when the
+// router built around them is swapped in. Where that path runs through a node that compaction merged from several
+// segments, the merged node is split back into one node per segment, and the copies are compacted again on the way
+// back up, so only the subtree the route lies in is ever rearranged.
+
+// with returns a router that also serves routes, or the first error any of them is rejected with (or all of them,
+// joined, if the router was built with Options.JoinErrors).
+func (this *builtRouter) with(routes []Route) (*builtRouter, error) {
+	config := this.config
+	config.Routes = append(this.Routes(), routes...)
+	trees := append([]routeTree(nil), this.trees...)
+	names := namedRoutes{}
+	for name, route := range this.names {
+		names[name] = route
+	}
+
+	var rejected []error
+	for _, route := range routes {
+		var err error
+		if trees, err = insert(config, trees, route); err == nil {
+			err = names.Add(route)
+		}
+
+		if err == nil {
+			continue
+		} else if !config.JoinErrors {
+			return nil, err
+		}
+		rejected = append(rejected, err)
+	}
+	if len(rejected) > 0 {
+		return nil, errors.Join(rejected...)
+	}
+
+	return assemble(config, trees, names), nil
+}
+
+// insert returns trees with route added to the tree for its host, whose root is replaced by an updated copy.
+func insert(config configuration, trees []routeTree, route Route) ([]routeTree, error) {
+	trees, index, err := treeFor(trees, route)
+	if err != nil {
+		return trees, err
+	}
+	endpoint, err := newRouteEndpoint(route, config.handler(route))
+	if err != nil {
+		return trees, err
+	}
+
+	root, err := trees[index].root.update(endpoint.template.segments, func(handlers *methodHandlers) error {
+		handlers.withoutImpliedHead()
+		err := handlers.Add(route.AllowedMethods, endpoint)
+		if config.ImplicitHead {
+			handlers.implyHead()
+		}
+		return err
+	})
+	if err != nil {
+		return trees, newRouteError(route, err)
+	}
+	trees[index].root = root
+	trees[index] = trees[index].withGroups(route.group, 1)
+	return trees, nil
+}
+
+// without returns a router that no longer serves routes, each of which must match a route it was given (see
+// routeIndex), along with the handlers that served them.
+func (this *builtRouter) without(routes []Route) (*builtRouter, []http.Handler, error) {
+	config := this.config
+	config.Routes = this.Routes()
+	trees := append([]routeTree(nil), this.trees...)
+	var handlers []http.Handler
+
+	for _, route := range routes {
+		position := routeIndex(config.Routes, route)
+		if position < 0 {
+			return nil, nil, ErrRouteNotFound
+		}
+		registered := config.Routes[position]
+		config.Routes = append(config.Routes[:position], config.Routes[position+1:]...)
+
+		// A route that was accepted once is valid, so neither its host nor its path can be rejected now.
+		var index int
+		trees, index, _ = treeFor(trees, registered)
+		template, _ := parsePathTemplate(registered.Path)
+		predicateKey, _ := newCandidateKey(registered.Predicates)
+		trees[index].root, _ = trees[index].root.update(template.segments, func(methods *methodHandlers) error {
+			methods.withoutImpliedHead()
+			handlers = append(handlers, methods.remove(registered.AllowedMethods, registered.Version, predicateKey))
+			if config.ImplicitHead {
+				methods.implyHead()
+			}
+			return nil
+		})
+		trees[index] = trees[index].withGroups(registered.group, -1)
+	}
+
+	// A host left without routes must no longer claim its requests from the tree that serves any host.
+	remaining := trees[:1]
+	for _, tree := range trees[1:] {
+		if !tree.root.empty() {
+			remaining = append(remaining, tree)
+		}
+	}
+	names := namedRoutes{}
+	for _, route := range config.Routes {
+		_ = names.Add(route) // the names were accepted together before, so fewer of them still are
+	}
+
+	return assemble(config, remaining, names), handlers, nil
+}
+
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+
+// update returns a copy of this node in which change has been made to the handlers of the node at the end of
+// segments, creating any node missing on the way there. A node left with nothing to route is pruned from its
+// parent's copy, though the root is always returned. Each copy's tally of methods (see treeNode.tally) is taken
+// again, so the root's reflects the change without the rest of the tree being visited.
+func (this *treeNode) update(segments []templateSegment, change func(*methodHandlers) error) (*treeNode, error) {
+	node := this.clone()
+	if len(segments) == 0 {
+		if node.handlers == nil {
+			node.handlers = &methodHandlers{}
+		} else {
+			node.handlers = node.handlers.clone()
+		}
+		err := change(node.handlers)
+		if node.handlers.allowed == 0 {
+			node.handlers = nil
+		}
+		node.tally()
+		return node, err
+	}
+
+	segment, remaining := segments[0], segments[1:]
+	switch segment.kind {
+	case segmentWildcard:
+		child := node.wildcard
+		if child == nil {
+			child = &treeNode{pathFragment: segment.text}
+		}
+		updated, err := child.update(remaining, change)
+		if err != nil {
+			return nil, err
+		}
+		node.wildcard = updated.unlessEmpty()
+	case segmentVariable:
+		child := node.addVariable(segment.text, segment.matcher)
+		updated, err := child.update(remaining, change)
+		if err != nil {
+			return nil, err
+		}
+		node.replaceVariable(child, updated.unlessEmpty())
+	default:
+		child := node.staticChild(segment.text)
+		if child == nil {
+			child = node.addStatic(segment.text)
+		} else if child.pathFragment != segment.text {
+			node.replaceStatic(child, child.split())
+			child = node.staticChild(segment.text)
+		}
+		updated, err := child.update(remaining, change)
+		if err != nil {
+			return nil, err
+		}
+		updated.absorbChain()
+		node.replaceStatic(child, updated.unlessEmpty())
+	}
+	node.tally()
+	return node, nil
+}
+
+// clone returns a copy of this node that can be changed without affecting it: its lists of children are its own,
+// though the children themselves are shared until they too are copied.
+func (this *treeNode) clone() *treeNode {
+	clone := *this
+	clone.static = append([]*treeNode(nil), this.static...)
+	clone.variables = append([]*treeNode(nil), this.variables...)
+	if this.staticIndex != nil {
+		clone.staticIndex = make(map[string]*treeNode, len(this.staticIndex))
+		for key, staticChild := range this.staticIndex {
+			clone.staticIndex[key] = staticChild
+		}
+	}
+	return &clone
+}
+
+// split undoes the compaction of this static node by one segment: it returns a node for the first segment of the
+// fragment, whose only child is a copy of this node holding the rest of the fragment.
+func (this *treeNode) split() *treeNode {
+	first, rest, _ := strings.Cut(this.pathFragment, "/")
+	remainder := *this
+	remainder.pathFragment = rest
+	return &treeNode{pathFragment: first, static: []*treeNode{&remainder}}
+}
+
+// staticChild returns the static child whose fragment begins with segment, which identifies it even once compaction
+// has extended the fragment by further segments.
+func (this *treeNode) staticChild(segment string) *treeNode {
+	for _, staticChild := range this.static {
+		if first, _, _ := strings.Cut(staticChild.pathFragment, "/"); first == segment {
+			return staticChild
+		}
+	}
+	return nil
+}
+func (this *treeNode) replaceStatic(previous, replacement *treeNode) {
+	key, _, _ := strings.Cut(previous.pathFragment, "/") // the key in staticIndex, which compaction never changes
+	for index, staticChild := range this.static {
+		if staticChild != previous {
+			continue
+		} else if replacement != nil {
+			this.static[index] = replacement
+		} else {
+			this.static = append(this.static[:index], this.static[index+1:]...)
+		}
+		break
+	}
+
+	if this.staticIndex == nil {
+		return
+	} else if replacement != nil {
+		this.staticIndex[key] = replacement
+	} else if len(this.static) < staticIndexThreshold {
+		this.staticIndex = nil // narrow again, as a node built with these children would be (see indexStatic)
+	} else {
+		delete(this.staticIndex, key)
+	}
+}
+func (this *treeNode) replaceVariable(previous, replacement *treeNode) {
+	for index, variableChild := range this.variables {
+		if variableChild != previous {
+			continue
+		} else if replacement != nil {
+			this.variables[index] = replacement
+		} else {
+			this.variables = append(this.variables[:index], this.variables[index+1:]...)
+		}
+		return
+	}
+}
+func (this *treeNode) empty() bool {
+	return this.handlers == nil && len(this.static) == 0 && len(this.variables) == 0 && this.wildcard == nil
+}
+func (this *treeNode) unlessEmpty() *treeNode {
+	if this.empty() {
+		return nil
+	}
+	return this
+}
+
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+
+// clone returns a copy of these handlers that can be changed without affecting them, down to the endpoint chains,
+// which Add extends in place.
+func (this *methodHandlers) clone() *methodHandlers {
+	clone := &methodHandlers{allowed: this.allowed, endpoints: make([]*endpoint, len(this.endpoints)), any: this.any.cloneChain()}
+	for index, endpoint := range this.endpoints {
+		clone.endpoints[index] = endpoint.cloneChain()
+	}
+	return clone
+}
+
+// remove takes the endpoint with the version and predicates given out of the chain of each of the methods given, and
+// returns the handler it was served by.
+func (this *methodHandlers) remove(allowed Method, version, predicateKey string) (handler http.Handler) {
+	for index := 1; index < 64; index++ {
+		method := Method(1) << index
+		if allowed&method != method {
+			continue
+		}
+
+		var previous *endpoint
+		for candidate := this.get(method); candidate != nil; previous, candidate = candidate, candidate.next {
+			if candidate.version != version || candidate.predicateKey != predicateKey {
+				continue
+			} else if handler = candidate.handler; previous == nil {
+				this.put(method, candidate.next)
+			} else {
+				previous.next = candidate.next
+			}
+			break
+		}
+		if this.get(method) == nil {
+			this.allowed &^= method
+		}
+	}
+	return handler
+}
+
+// withoutImpliedHead removes the HEAD endpoints implied by GET endpoints (see implyHead), so the methods can be
+// changed and HEAD implied again from what GET then serves.
+func (this *methodHandlers) withoutImpliedHead() {
+	if head := this.get(MethodHead); head != nil && head.route.AllowedMethods&MethodHead == 0 {
+		this.put(MethodHead, nil)
+		this.allowed &^= MethodHead
+	}
+}
+
+func (this *endpoint) cloneChain() *endpoint {
+	if this == nil {
+		return nil
+	}
+	clone := *this
+	clone.next = this.next.cloneChain()
+	return &clone
+}
+
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+
+// trackedHandler counts the requests a route is serving, so that removing the route can wait for them to finish.
+type trackedHandler struct {
+	http.Handler
+	active atomic.Int64
+}
+
+func (this *trackedHandler) ServeHTTP(response http.ResponseWriter, request *http.Request) {
+	this.active.Add(1)
+	defer this.active.Add(-1)
+	this.Handler.ServeHTTP(response, request)
+}
+
+// drain waits until no request is being served, or until ctx is done. It is called once the route is no longer in the
+// router serving requests, but a request is counted only once it enters the handler: one that was resolved against the
+// routes as they were and has yet to reach the handler is not waited for, and may begin after drain has returned.
+func (this *trackedHandler) drain(ctx context.Context) error {
+	ticker := time.NewTicker(drainInterval)
+	defer ticker.Stop()
+
+	for this.active.Load() > 0 {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+	return nil
+}
+
+const drainInterval = 5 * time.Millisecond
@@ -0,0 +1,161 @@
+package httprouter
+
+import (
+	"net/url"
+	"strings"
+)
+
+// namedRoutes holds the path template of every route given a Name, from which Router.URL builds paths.
+type namedRoutes map[string]namedRoute
+type namedRoute struct {
+	path     string
+	template pathTemplate
+}
+
+// Add records the route under its name, if it has one. Several routes may share a name only if they share a path
+// (e.g. "GET /users/:id" and "DELETE /users/:id" registered separately), as the name must identify a single path.
+func (this namedRoutes) Add(route Route) error {
+	if len(route.Name) == 0 {
+		return nil
+	} else if existing, found := this[route.Name]; found {
+		if existing.path != route.Path {
+			return newRouteError(route, ErrDuplicateRouteName)
+		}
+		return nil
+	}
+
+	template, err := parsePathTemplate(route.Path)
+	if err != nil {
+		return newRouteError(route, err)
+	}
+	this[route.Name] = namedRoute{path: route.Path, template: template}
+	return nil
+}
+
+// URL builds the path of the route with the name given, from params given as name/value pairs (the remainder of a
+// wildcard under WildcardParam), each name given once.
+func (this namedRoutes) URL(name string, params []string) (string, error) {
+	route, found := this[name]
+	if !found {
+		return "", ErrUnknownRouteName
+	} else if len(params)%2 != 0 {
+		return "", ErrMissingParam // a name without its value
+	}
+
+	values := make(map[string]string, len(params)/2)
+	for index := 0; index < len(params); index += 2 {
+		if _, found := values[params[index]]; found {
+			return "", ErrDuplicateParam
+		}
+		values[params[index]] = params[index+1]
+	}
+	return route.template.expand(values)
+}
+
+// expand returns the path this template matches with values (by parameter name) in place of its variables and
+// wildcard, percent-encoded as the router compares paths. Every value must be used exactly once, and the path built
+// must resolve to this template with the very same values, so a value that fails its constraint or that the tree
+// would split differently (e.g. "a.b" for the "ext" of ":name.:ext") is rejected rather than producing a path that
+// routes elsewhere.
+func (this pathTemplate) expand(values map[string]string) (string, error) {
+	if len(this.segments) == 0 {
+		return "", nil
+	}
+
+	var builder strings.Builder
+	used := 0
+	take := func(name string) (string, error) {
+		value, found := values[name]
+		if !found {
+			return "", ErrMissingParam
+		}
+		used++
+		return value, nil
+	}
+
+	for _, segment := range this.segments {
+		builder.WriteByte('/')
+		switch {
+		case segment.kind == segmentStatic:
+			builder.WriteString(segment.text)
+		case segment.kind == segmentWildcard:
+			value, err := take(WildcardParam)
+			if err != nil {
+				return "", err
+			}
+			for index, part := range strings.Split(value, "/") {
+				if part == "." || part == ".." {
+					return "", ErrInvalidParam
+				} else if index > 0 {
+					builder.WriteByte('/')
+				}
+				builder.WriteString(escapeSegment(part))
+			}
+		case segment.matcher == nil:
+			value, err := take(segment.name)
+			if err != nil {
+				return "", err
+			} else if !isExpressible(value) {
+				return "", ErrInvalidParam
+			}
+			builder.WriteString(escapeSegment(value))
+		default:
+			if err := segment.matcher.expand(&builder, take); err != nil {
+				return "", err
+			}
+		}
+	}
+
+	if used < len(values) {
+		return "", ErrExtraParam
+	}
+	return builder.String(), nil
+}
+
+// expand writes the segment this matcher accepts with the values that take returns for its variables, and checks
+// that the matcher captures exactly those values from it.
+func (this *segmentMatcher) expand(builder *strings.Builder, take func(string) (string, error)) error {
+	var segment strings.Builder
+	var expected Params
+	for _, part := range this.parts {
+		if !part.variable {
+			segment.WriteString(part.literal)
+			continue
+		}
+
+		value, err := take(part.name)
+		if err != nil {
+			return err
+		} else if len(value) == 0 {
+			return ErrInvalidParam
+		}
+		expected = append(expected, Param{Name: part.name, Value: escapeSegment(value)})
+		segment.WriteString(escapeSegment(value))
+	}
+
+	captured := this.capture(segment.String(), nil)
+	if len(captured) != len(expected) {
+		return ErrInvalidParam
+	}
+	for index := range captured {
+		if captured[index] != expected[index] {
+			return ErrInvalidParam
+		}
+	}
+
+	builder.WriteString(segment.String())
+	return nil
+}
+
+// escapeSegment percent-encodes value for use as (part of) a single path segment, in the canonical form the router
+// compares paths in, so that a '/' in a value stays within its segment.
+func escapeSegment(value string) string {
+	escaped, _ := normalizeEncoding(url.PathEscape(value))
+	return escaped
+}
+
+// isExpressible reports whether value can stand for a whole segment: it must not be empty, which no variable matches,
+// nor a dot segment, which clients remove from a path before sending it.
+func isExpressible(value string) bool {
+	return len(value) > 0 && value != "." && value != ".."
+}
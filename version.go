@@ -0,0 +1,34 @@
+package httprouter
+
+import (
+	"strconv"
+	"strings"
+)
+
+// compareVersions orders versions by their dot-separated parts, numerically where both parts are numbers, ignoring a
+// leading "v" and treating missing parts as zero, so "v2" and "2.0" are the same version.
+func compareVersions(left, right string) int {
+	left, right = strings.TrimPrefix(strings.ToLower(left), "v"), strings.TrimPrefix(strings.ToLower(right), "v")
+	for len(left) > 0 || len(right) > 0 {
+		var leftPart, rightPart string
+		leftPart, left, _ = strings.Cut(left, ".")
+		rightPart, right, _ = strings.Cut(right, ".")
+
+		leftNumber, leftErr := strconv.Atoi(defaultString(leftPart, "0"))
+		rightNumber, rightErr := strconv.Atoi(defaultString(rightPart, "0"))
+		if leftErr == nil && rightErr == nil {
+			if leftNumber != rightNumber {
+				return leftNumber - rightNumber
+			}
+		} else if comparison := strings.Compare(leftPart, rightPart); comparison != 0 {
+			return comparison
+		}
+	}
+	return 0
+}
+func defaultString(value, fallback string) string {
+	if len(value) == 0 {
+		return fallback
+	}
+	return value
+}
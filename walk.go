@@ -0,0 +1,56 @@
+package httprouter
+
+// routeTree is one of the trees a router resolves paths with: the one serving any host, or that of a host pattern.
+type routeTree struct {
+	pattern *hostPattern // nil for the tree serving any host
+	root    *treeNode
+	groups  map[*groupScope]int // the number of routes in the tree beneath each group scope; see withGroups
+}
+
+// walk calls visit for this node and every node beneath it, depth first and in the order Resolve tries them:
+// static children, then variables, then the wildcard. A node where several routes end is visited once for each of
+// them, and one where none does is visited once with the zero Route.
+func (this *treeNode) walk(info NodeInfo, visit func(Route, NodeInfo)) {
+	info.Methods = 0
+	var routes []Route
+	if this.handlers != nil {
+		info.Methods, routes = this.handlers.allowed, this.handlers.routes()
+	}
+	if len(routes) == 0 {
+		visit(Route{}, info)
+	}
+	for _, route := range routes {
+		visit(route, info)
+	}
+
+	child := NodeInfo{Host: info.Host, Depth: info.Depth + 1, Kind: FragmentStatic, Indexed: this.staticIndex != nil}
+	for _, staticChild := range this.static {
+		child.Fragment = staticChild.pathFragment
+		staticChild.walk(child, visit)
+	}
+	child.Kind, child.Indexed = FragmentVariable, false
+	for _, variableChild := range this.variables {
+		child.Fragment = variableChild.pathFragment
+		variableChild.walk(child, visit)
+	}
+	if this.wildcard != nil {
+		child.Kind, child.Fragment = FragmentWildcard, this.wildcard.pathFragment
+		this.wildcard.walk(child, visit)
+	}
+}
+
+// routes returns each route registered here once, in method order and then in the order each method's candidates
+// are tried. A route registered for several methods, or served for HEAD through its GET handler, has an endpoint
+// for each method that all carry the same route.
+func (this *methodHandlers) routes() (routes []Route) {
+	seen := map[string]bool{}
+	for _, endpoint := range append(this.endpoints, this.any) {
+		for ; endpoint != nil; endpoint = endpoint.next {
+			if key := endpoint.route.String() + "\x00" + endpoint.version + "\x00" + endpoint.predicateKey; !seen[key] {
+				seen[key] = true
+				routes = append(routes, endpoint.route)
+			}
+		}
+	}
+	return routes
+}